@@ -0,0 +1,181 @@
+package autoinstrument
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"unsafe"
+
+	ddtesting "github.com/DataDog/dd-sdk-go-testing"
+)
+
+var (
+	benchmarkContextMutex sync.RWMutex
+	benchmarkContextMap   = map[*testing.B]context.Context{}
+
+	// pendingBenchmark is the top-level benchmark whose span is not finished yet, and
+	// pendingFinish the function finishing it.
+	pendingBenchmarkMutex sync.Mutex
+	pendingBenchmark      *testing.B
+	pendingFinish         ddtesting.FinishFunc
+)
+
+// RunB runs f as a sub-benchmark of b, traced as a child span of the span of b.
+func RunB(b *testing.B, name string, f func(b *testing.B)) bool {
+	return runBenchmark(GetBenchmarkContext(b), b, name, f)
+}
+
+// GetBenchmarkContext returns the context holding the span of an instrumented benchmark.
+func GetBenchmarkContext(b *testing.B) context.Context {
+	benchmarkContextMutex.RLock()
+	defer benchmarkContextMutex.RUnlock()
+	if ctx, ok := benchmarkContextMap[b]; ok {
+		return ctx
+	}
+	return context.Background()
+}
+
+func hasBenchmarkContext(b *testing.B) bool {
+	benchmarkContextMutex.RLock()
+	defer benchmarkContextMutex.RUnlock()
+	_, ok := benchmarkContextMap[b]
+	return ok
+}
+
+func setBenchmarkContext(b *testing.B, ctx context.Context) {
+	benchmarkContextMutex.Lock()
+	defer benchmarkContextMutex.Unlock()
+	benchmarkContextMap[b] = ctx
+}
+
+func deleteBenchmarkContext(b *testing.B) {
+	benchmarkContextMutex.Lock()
+	defer benchmarkContextMutex.Unlock()
+	delete(benchmarkContextMap, b)
+}
+
+// instrumentBenchmark returns the benchmark with its function traced, its failures recorded in
+// attempts. The testing package calls a benchmark function once per run with an increasing b.N,
+// so the span starts with the first run and finishes with the results of the last run, when the
+// next benchmark starts or when the benchmark running the top-level benchmarks finishes.
+func instrumentBenchmark(bench testing.InternalBenchmark, attempts *executions) testing.InternalBenchmark {
+	benchFn := bench.F
+	instrumented := bench
+	instrumented.F = func(b *testing.B) {
+		defer func() {
+			if b.Failed() {
+				attempts.fail()
+			}
+		}()
+
+		parent := benchmarkParent(b)
+		if parent == nil {
+			// Nothing tells when the last run finished: one span per run of the benchmark function.
+			ctx, finish := ddtesting.StartTestWithContext(context.Background(), b, ddtesting.WithOriginalFunc(benchFn))
+			setBenchmarkContext(b, ctx)
+			defer deleteBenchmarkContext(b)
+			defer finish()
+			benchFn(b)
+			return
+		}
+
+		if !hasBenchmarkContext(b) {
+			finishPendingBenchmark()
+			ctx, finish := ddtesting.StartTestWithContext(context.Background(), b, ddtesting.WithOriginalFunc(benchFn))
+			setBenchmarkContext(b, ctx)
+			pendingBenchmarkMutex.Lock()
+			pendingBenchmark, pendingFinish = b, finish
+			pendingBenchmarkMutex.Unlock()
+			parent.Cleanup(finishPendingBenchmark)
+		}
+		defer func() {
+			if r := recover(); r != nil {
+				// Benchmark panics are not recovered by testing and end the process: let finish
+				// report the panic, flush the span and panic again.
+				_, finish := takePendingBenchmark()
+				func() {
+					if finish != nil {
+						defer finish()
+					}
+					panic(r)
+				}()
+			}
+		}()
+		benchFn(b)
+	}
+	return instrumented
+}
+
+// takePendingBenchmark returns the top-level benchmark whose span is not finished yet and the
+// function finishing it, which the caller must call.
+func takePendingBenchmark() (*testing.B, ddtesting.FinishFunc) {
+	pendingBenchmarkMutex.Lock()
+	defer pendingBenchmarkMutex.Unlock()
+	b, finish := pendingBenchmark, pendingFinish
+	pendingBenchmark, pendingFinish = nil, nil
+	return b, finish
+}
+
+// finishPendingBenchmark finishes the span of the last top-level benchmark if it's not finished.
+func finishPendingBenchmark() {
+	if b, finish := takePendingBenchmark(); finish != nil {
+		finish()
+		deleteBenchmarkContext(b)
+	}
+}
+
+// benchmarkParent returns the benchmark running b, which is the benchmark of go test running the
+// top-level benchmarks, or nil when b runs on its own as with testing.Benchmark.
+func benchmarkParent(b *testing.B) *testing.B {
+	parent := reflect.ValueOf(b).Elem().FieldByName("parent")
+	if parent.Kind() != reflect.Ptr || parent.IsNil() {
+		return nil
+	}
+	// The parent field points to the common struct embedded first in the testing.B of the parent.
+	return (*testing.B)(unsafe.Pointer(parent.Pointer()))
+}
+
+// runBenchmark runs f as a sub-benchmark of b in a span child of the span in ctx. The span
+// starts with the first run of the sub-benchmark and finishes when b.Run returns, with the
+// results of the last run.
+func runBenchmark(ctx context.Context, b *testing.B, name string, f func(b *testing.B)) bool {
+	var sub *testing.B
+	var finish ddtesting.FinishFunc
+
+	ok := b.Run(name, func(b *testing.B) {
+		if sub == nil {
+			sub = b
+			var subCtx context.Context
+			subCtx, finish = ddtesting.StartTestWithContext(ctx, b, ddtesting.WithOriginalFunc(f))
+			setBenchmarkContext(b, subCtx)
+		}
+		defer func() {
+			if r := recover(); r != nil {
+				// Benchmark panics are not recovered by testing and end the process: let finish
+				// report the panic, flush the span and panic again.
+				func() {
+					defer finish()
+					panic(r)
+				}()
+			}
+		}()
+		f(b)
+	})
+
+	if finish != nil {
+		finish()
+		deleteBenchmarkContext(sub)
+	}
+	return ok
+}
+
+// get the pointer to the internal benchmark array
+func getInternalBenchmarkArray(m *testing.M) *[]testing.InternalBenchmark {
+	indirectValue := reflect.Indirect(reflect.ValueOf(m))
+	member := indirectValue.FieldByName("benchmarks")
+	if member.IsValid() {
+		return (*[]testing.InternalBenchmark)(unsafe.Pointer(member.UnsafeAddr()))
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package autoinstrument
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/constants"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+)
+
+func BenchmarkRunB(b *testing.B) {
+	RunB(b, "sub01", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			time.Sleep(time.Microsecond)
+		}
+	})
+}
+
+func TestInstrumentBenchmark(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	calls := 0
+	benchmark := instrumentBenchmark(testing.InternalBenchmark{
+		Name: "BenchmarkSleep",
+		F: func(b *testing.B) {
+			RunB(b, "sub", func(b *testing.B) {
+				calls++
+				for i := 0; i < b.N; i++ {
+					time.Sleep(time.Microsecond)
+				}
+			})
+		},
+	}, newExecutions(new(config)))
+	testing.Benchmark(func(b *testing.B) {
+		// Run it as a top-level benchmark of go test, below the benchmark running them.
+		b.Run(benchmark.Name, benchmark.F)
+	})
+
+	spans := mt.FinishedSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if calls < 2 {
+		t.Fatalf("expected several runs of the sub-benchmark, got %d", calls)
+	}
+
+	sub, parent := spans[0], spans[1]
+	if sub.ParentID() != parent.SpanID() {
+		t.Fatal("the sub-benchmark span is not a child of the benchmark span")
+	}
+	for _, s := range spans {
+		if testType := s.Tag(constants.TestType); testType != constants.TestTypeBenchmark {
+			t.Fatalf("unexpected test type: %v", testType)
+		}
+		if status := s.Tag(constants.TestStatus); status != constants.TestStatusPass {
+			t.Fatalf("unexpected status: %v", status)
+		}
+	}
+	if runs := fmt.Sprint(sub.Tag(constants.BenchmarkRuns)); runs == "1" {
+		t.Fatal("the sub-benchmark span holds the results of its first run")
+	}
+
+	// The benchmarks keep their name in the output of go test.
+	cmd := exec.Command(os.Args[0], "-test.run=^$", "-test.bench=^BenchmarkRunB$", "-test.benchtime=1x")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	if !regexp.MustCompile(`(?m)^BenchmarkRunB/sub01(-\d+)?\s`).Match(out) || strings.Contains(string(out), "BenchmarkRunB/BenchmarkRunB") {
+		t.Fatalf("unexpected benchmark names:\n%s", out)
+	}
+}
@@ -0,0 +1,28 @@
+package autoinstrument
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/settings"
+	"github.com/DataDog/dd-sdk-go-testing/internal/utils"
+)
+
+// isNewTest reports whether the test is missing from the known tests list.
+func isNewTest(name string, testFn func(*testing.T)) bool {
+	suite, _ := utils.GetPackageAndName(reflect.ValueOf(testFn).Pointer())
+	return settings.IsNewTest(utils.TestFQN(suite, name))
+}
+
+// detectFlakes executes a new test again as many times as its duration allows and reports
+// whether any of its executions passed.
+func detectFlakes(t *testing.T, name string, testFn func(*testing.T), duration time.Duration) bool {
+	passed := !t.Failed()
+	for i := 1; i < settings.EarlyFlakeDetectionExecutions(duration); i++ {
+		if runAttempt(t, name, testFn, i) {
+			passed = true
+		}
+	}
+	return passed
+}
@@ -0,0 +1,112 @@
+package autoinstrument
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+
+	ddtesting "github.com/DataDog/dd-sdk-go-testing"
+	"github.com/DataDog/dd-sdk-go-testing/internal/constants"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// exampleTB reports the name and result of an example, which has no testing.TB of its own.
+type exampleTB struct {
+	name   string
+	failed bool
+}
+
+var _ ddtesting.TB = (*exampleTB)(nil)
+
+func (e *exampleTB) Failed() bool  { return e.failed }
+func (e *exampleTB) Name() string  { return e.name }
+func (e *exampleTB) Skipped() bool { return false }
+
+// instrumentExample returns the example with its function traced, its failures recorded in
+// attempts.
+func instrumentExample(eg testing.InternalExample, attempts *executions) testing.InternalExample {
+	exampleFn := eg.F
+	instrumented := eg
+	instrumented.F = func() {
+		if runExample(eg, exampleFn) {
+			attempts.fail()
+		}
+	}
+	return instrumented
+}
+
+// runExample runs the example function in a test span. The output of the example is captured
+// to be compared with the expected output, then written to the output captured by the testing
+// package, which still reports the example result. It returns whether the example failed.
+func runExample(eg testing.InternalExample, exampleFn func()) (failed bool) {
+	tb := &exampleTB{name: eg.Name}
+	ctx, finish := ddtesting.StartTestWithContext(context.Background(), tb,
+		ddtesting.WithOriginalFunc(exampleFn),
+		ddtesting.WithSpanOptions(tracer.Tag(constants.TestType, constants.TestTypeExample)))
+	defer finish()
+
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		exampleFn()
+		return false
+	}
+	os.Stdout = w
+	outC := make(chan string)
+	go func() {
+		var buf strings.Builder
+		io.Copy(&buf, r)
+		r.Close()
+		outC <- buf.String()
+	}()
+
+	finished := false
+	defer func() {
+		w.Close()
+		os.Stdout = stdout
+		out := <-outC
+		io.WriteString(stdout, out)
+
+		if !finished {
+			// The example panicked, which finish reports, or called runtime.Goexit.
+			tb.failed = true
+		} else if got, want, ok := exampleOutputMatches(eg, out); !ok {
+			tb.failed = true
+			span, _ := tracer.SpanFromContext(ctx)
+			span.SetTag(ext.ErrorType, "output mismatch")
+			span.SetTag(ext.ErrorMsg, fmt.Sprintf("got:\n%s\nwant:\n%s\n", got, want))
+		}
+		failed = tb.failed
+	}()
+
+	exampleFn()
+	finished = true
+	return false
+}
+
+// exampleOutputMatches compares the output of an example with its expected output the way the
+// testing package does, and returns both of them as compared.
+func exampleOutputMatches(eg testing.InternalExample, stdout string) (got string, want string, ok bool) {
+	got = strings.TrimSpace(stdout)
+	want = strings.TrimSpace(eg.Output)
+	if runtime.GOOS == "windows" {
+		got = strings.ReplaceAll(got, "\r\n", "\n")
+		want = strings.ReplaceAll(want, "\r\n", "\n")
+	}
+	if eg.Unordered {
+		return got, want, sortLines(got) == sortLines(want)
+	}
+	return got, want, got == want
+}
+
+func sortLines(output string) string {
+	lines := strings.Split(output, "\n")
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
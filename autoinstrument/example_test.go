@@ -0,0 +1,58 @@
+package autoinstrument
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/constants"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+)
+
+func ExampleRunM() {
+	fmt.Println("instrumented example")
+	// Output: instrumented example
+}
+
+func TestInstrumentExample(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	examples := []testing.InternalExample{
+		{Name: "ExamplePass", F: func() { fmt.Println("a\nb") }, Output: "a\nb\n"},
+		{Name: "ExampleUnordered", F: func() { fmt.Println("b\na") }, Output: "a\nb\n", Unordered: true},
+		{Name: "ExampleMismatch", F: func() { fmt.Println("b\na") }, Output: "a\nb\n"},
+	}
+	attempts := newExecutions(new(config))
+	for _, eg := range examples {
+		instrumentExample(eg, attempts).F()
+	}
+	if !attempts.unretried {
+		t.Fatal("the failed example was not recorded")
+	}
+
+	spans := mt.FinishedSpans()
+	if len(spans) != len(examples) {
+		t.Fatalf("expected %d spans, got %d", len(examples), len(spans))
+	}
+	for i, s := range spans {
+		if name := s.Tag(constants.TestName); name != examples[i].Name {
+			t.Fatalf("unexpected test name: %v", name)
+		}
+		if testType := s.Tag(constants.TestType); testType != constants.TestTypeExample {
+			t.Fatalf("unexpected test type: %v", testType)
+		}
+	}
+
+	for _, s := range spans[:2] {
+		if status := s.Tag(constants.TestStatus); status != constants.TestStatusPass {
+			t.Fatalf("%v: unexpected status %v", s.Tag(constants.TestName), status)
+		}
+	}
+	if status := spans[2].Tag(constants.TestStatus); status != constants.TestStatusFail {
+		t.Fatalf("unexpected status of the mismatching example: %v", status)
+	}
+	if msg := spans[2].Tag(ext.ErrorMsg); msg != "got:\nb\na\nwant:\na\nb\n" {
+		t.Fatalf("unexpected error message: %q", msg)
+	}
+}
@@ -0,0 +1,53 @@
+//go:build go1.18
+// +build go1.18
+
+package autoinstrument
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"unsafe"
+
+	ddtesting "github.com/DataDog/dd-sdk-go-testing"
+)
+
+// FuzzTargets returns the fuzz tests with their functions traced. The seed corpus entries run
+// in the fuzz test span, as child spans only when the fuzz test calls ddtesting.Fuzz.
+func (i *Instrumentation) FuzzTargets(fuzzTargets []testing.InternalFuzzTarget) []testing.InternalFuzzTarget {
+	newFuzzTargetArray := make([]testing.InternalFuzzTarget, len(fuzzTargets))
+	for idx, target := range fuzzTargets {
+		fuzzFn := target.Fn
+		newFuzzTargetArray[idx] = testing.InternalFuzzTarget{
+			Name: target.Name,
+			Fn: func(f *testing.F) {
+				defer func() {
+					if f.Failed() {
+						i.attempts.fail()
+					}
+				}()
+				_, finish := ddtesting.StartTestWithContext(context.Background(), f, ddtesting.WithOriginalFunc(fuzzFn))
+				defer finish()
+				fuzzFn(f)
+			},
+		}
+	}
+	return newFuzzTargetArray
+}
+
+// instrumentFuzzTargets instruments the fuzz tests of m.
+func instrumentFuzzTargets(m *testing.M, i *Instrumentation) {
+	if internalFuzzTargets := getInternalFuzzTargetArray(m); internalFuzzTargets != nil {
+		*internalFuzzTargets = i.FuzzTargets(*internalFuzzTargets)
+	}
+}
+
+// get the pointer to the internal fuzz target array
+func getInternalFuzzTargetArray(m *testing.M) *[]testing.InternalFuzzTarget {
+	indirectValue := reflect.Indirect(reflect.ValueOf(m))
+	member := indirectValue.FieldByName("fuzzTargets")
+	if member.IsValid() {
+		return (*[]testing.InternalFuzzTarget)(unsafe.Pointer(member.UnsafeAddr()))
+	}
+	return nil
+}
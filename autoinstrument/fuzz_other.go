@@ -0,0 +1,9 @@
+//go:build !go1.18
+// +build !go1.18
+
+package autoinstrument
+
+import "testing"
+
+// instrumentFuzzTargets traces the fuzz tests of m, which require Go 1.18.
+func instrumentFuzzTargets(m *testing.M, i *Instrumentation) {}
@@ -18,38 +18,36 @@ var (
 // Implementation for auto instrumentation
 
 func Run(t *testing.T, name string, f func(t *testing.T)) bool {
+	parentCtx := GetContext(t)
 	return t.Run(name, func(t *testing.T) {
-		_, finish := ddtesting.StartTestWithContext(GetContext(t), t, ddtesting.WithOriginalTestFunc(f))
+		ctx, finish := ddtesting.StartTestWithContext(parentCtx, t, ddtesting.WithOriginalTestFunc(f))
+		setContext(t, ctx)
+		defer deleteContext(t)
 		defer finish()
 		f(t)
 	})
 }
 
-func RunM(m *testing.M) int {
+func RunM(m *testing.M, opts ...Option) int {
+	i := NewInstrumentation(opts...)
 
-	// Let's access to the inner Test array and instrument them
-	internalTests := getInternalTestArray(m)
-	if internalTests != nil {
-		newTestArray := make([]testing.InternalTest, len(*internalTests))
-		for idx, test := range *internalTests {
-			testFn := test.F
-			newTestArray[idx] = testing.InternalTest{
-				Name: test.Name,
-				F: func(t *testing.T) {
-					_, finish := ddtesting.StartTestWithContext(GetContext(t), t, ddtesting.WithOriginalTestFunc(testFn))
-					defer finish()
-					testFn(t)
-				},
-			}
-		}
-		*internalTests = newTestArray
+	// Let's access to the inner arrays of m and instrument them
+	if internalTests := getInternalTestArray(m); internalTests != nil {
+		*internalTests = i.Tests(*internalTests)
 	}
+	if internalBenchmarks := getInternalBenchmarkArray(m); internalBenchmarks != nil {
+		*internalBenchmarks = i.Benchmarks(*internalBenchmarks)
+	}
+	if internalExamples := getInternalExampleArray(m); internalExamples != nil {
+		*internalExamples = i.Examples(*internalExamples)
+	}
+	instrumentFuzzTargets(m, i)
 
-	return ddtesting.Run(m)
+	return i.Run(m)
 }
 
-func RunTestMain(m *testing.M) {
-	os.Exit(RunM(m))
+func RunTestMain(m *testing.M, opts ...Option) {
+	os.Exit(RunM(m, opts...))
 }
 
 // get the pointer to the internal test array
@@ -62,18 +60,35 @@ func getInternalTestArray(m *testing.M) *[]testing.InternalTest {
 	return nil
 }
 
+// get the pointer to the internal example array
+func getInternalExampleArray(m *testing.M) *[]testing.InternalExample {
+	indirectValue := reflect.Indirect(reflect.ValueOf(m))
+	member := indirectValue.FieldByName("examples")
+	if member.IsValid() {
+		return (*[]testing.InternalExample)(unsafe.Pointer(member.UnsafeAddr()))
+	}
+	return nil
+}
+
+// GetContext returns the context holding the span of an instrumented test, which is the parent
+// of the spans of its subtests.
 func GetContext(t *testing.T) context.Context {
-	// Read lock
 	contextMutex.RLock()
+	defer contextMutex.RUnlock()
 	if ctx, ok := contextMap[t]; ok {
 		return ctx
 	}
-	contextMutex.RUnlock()
+	return context.Background()
+}
 
-	// Write lock
-	ctx := context.Background()
+func setContext(t *testing.T, ctx context.Context) {
 	contextMutex.Lock()
+	defer contextMutex.Unlock()
 	contextMap[t] = ctx
-	contextMutex.Unlock()
-	return ctx
+}
+
+func deleteContext(t *testing.T) {
+	contextMutex.Lock()
+	defer contextMutex.Unlock()
+	delete(contextMap, t)
 }
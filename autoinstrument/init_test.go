@@ -2,6 +2,8 @@ package autoinstrument
 
 import (
 	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
 )
 
 func TestMain(m *testing.M) {
@@ -24,3 +26,20 @@ func TestMyTest02(t *testing.T) {
 
 	})
 }
+
+func TestRunChildSpans(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	Run(t, "parent", func(t *testing.T) {
+		Run(t, "child", func(t *testing.T) {})
+	})
+
+	spans := mt.FinishedSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if spans[0].ParentID() != spans[1].SpanID() {
+		t.Fatal("the subtest span is not a child of the test span")
+	}
+}
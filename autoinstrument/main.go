@@ -0,0 +1,89 @@
+package autoinstrument
+
+import (
+	"testing"
+	"time"
+
+	ddtesting "github.com/DataDog/dd-sdk-go-testing"
+	"github.com/DataDog/dd-sdk-go-testing/internal/coverage"
+)
+
+// Instrumentation instruments the tests, benchmarks, fuzz tests and examples of a test binary
+// before they're given to testing.MainStart, then runs them. It's used by the test main function
+// generated by go test and rewritten by ddtest-toolexec, without the reflection into testing.M
+// RunM relies on:
+//
+//	func main() {
+//		i := autoinstrument.NewInstrumentation()
+//		tests = i.Tests(tests)
+//		benchmarks = i.Benchmarks(benchmarks)
+//		fuzzTargets = i.FuzzTargets(fuzzTargets)
+//		examples = i.Examples(examples)
+//		m := testing.MainStart(testdeps.TestDeps{}, tests, benchmarks, fuzzTargets, examples)
+//		os.Exit(i.Run(m))
+//	}
+type Instrumentation struct {
+	attempts *executions
+}
+
+// NewInstrumentation returns an Instrumentation configured with the options.
+func NewInstrumentation(opts ...Option) *Instrumentation {
+	cfg := new(config)
+	defaults(cfg)
+	for _, fn := range opts {
+		fn(cfg)
+	}
+	return &Instrumentation{attempts: newExecutions(cfg)}
+}
+
+// Tests returns the tests with their functions traced, and retried according to the options.
+func (i *Instrumentation) Tests(tests []testing.InternalTest) []testing.InternalTest {
+	newTestArray := make([]testing.InternalTest, len(tests))
+	for idx, test := range tests {
+		testName := test.Name
+		testFn := test.F
+		newTestArray[idx] = testing.InternalTest{
+			Name: test.Name,
+			F: func(t *testing.T) {
+				defer i.attempts.run(t, testName, testFn, time.Now())
+				ctx, finish := ddtesting.StartTestWithContext(GetContext(t), t, ddtesting.WithOriginalTestFunc(testFn))
+				setContext(t, ctx)
+				defer deleteContext(t)
+				defer finish()
+				testFn(t)
+			},
+		}
+	}
+	return newTestArray
+}
+
+// Benchmarks returns the benchmarks with their functions traced.
+func (i *Instrumentation) Benchmarks(benchmarks []testing.InternalBenchmark) []testing.InternalBenchmark {
+	newBenchmarkArray := make([]testing.InternalBenchmark, len(benchmarks))
+	for idx, benchmark := range benchmarks {
+		newBenchmarkArray[idx] = instrumentBenchmark(benchmark, i.attempts)
+	}
+	return newBenchmarkArray
+}
+
+// Examples returns the examples with their functions traced.
+func (i *Instrumentation) Examples(examples []testing.InternalExample) []testing.InternalExample {
+	newExampleArray := make([]testing.InternalExample, len(examples))
+	for idx, example := range examples {
+		newExampleArray[idx] = instrumentExample(example, i.attempts)
+	}
+	return newExampleArray
+}
+
+// RegisterCoverage sets the function writing the coverage profile of the test binary,
+// testdeps.CoverProcessTestDirFunc, so that the blocks executed by each test are reported when
+// DD_CIVISIBILITY_CODE_COVERAGE_ENABLED is set. It's called by the test main functions rewritten by
+// ddtest-toolexec of the test binaries built with -cover.
+func RegisterCoverage(processTestDir interface{}) {
+	coverage.Register(processTestDir)
+}
+
+// Run runs the tests of m in a test session, and returns the exit code of the test run.
+func (i *Instrumentation) Run(m *testing.M) int {
+	return i.attempts.exitCode(ddtesting.Run(m))
+}
@@ -0,0 +1,54 @@
+package autoinstrument
+
+import (
+	"os"
+	"strings"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/utils"
+)
+
+// RetryPolicy defines the exit code of a test run where failed tests passed on retry.
+//
+// The retries run as subtests of the failed test, which the testing package still reports as
+// failed in the output of go test and in the JUnit report: only the exit code of the test run
+// and the test spans reflect the policy.
+type RetryPolicy string
+
+const (
+	// RetryPolicyFail keeps the failing exit code even if every failed test passed on retry.
+	RetryPolicyFail RetryPolicy = "fail"
+
+	// RetryPolicyPass returns a successful exit code if every failed test passed on retry.
+	RetryPolicyPass RetryPolicy = "pass"
+)
+
+type config struct {
+	flakyRetryCount  int
+	flakyRetryPolicy RetryPolicy
+}
+
+// Option represents an option that can be passed to RunM.
+type Option func(*config)
+
+func defaults(cfg *config) {
+	cfg.flakyRetryCount = utils.IntEnv("DD_CIVISIBILITY_FLAKY_RETRY_COUNT", 0)
+	cfg.flakyRetryPolicy = RetryPolicyFail
+	if strings.ToLower(os.Getenv("DD_CIVISIBILITY_FLAKY_RETRY_POLICY")) == string(RetryPolicyPass) {
+		cfg.flakyRetryPolicy = RetryPolicyPass
+	}
+}
+
+// WithFlakyRetries sets how many times a failed top-level test is retried.
+// Zero disables the retries.
+func WithFlakyRetries(count int) Option {
+	return func(cfg *config) {
+		cfg.flakyRetryCount = count
+	}
+}
+
+// WithRetryPolicy sets the exit code policy applied when failed tests pass on retry.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(cfg *config) {
+		cfg.flakyRetryPolicy = policy
+	}
+}
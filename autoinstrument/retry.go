@@ -0,0 +1,114 @@
+package autoinstrument
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	ddtesting "github.com/DataDog/dd-sdk-go-testing"
+	"github.com/DataDog/dd-sdk-go-testing/internal/constants"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// executions runs the additional attempts of instrumented top-level tests, either the early
+// flake detection executions of a new test or the retries of a failed test, and records
+// whether the failed tests passed on a later attempt, and whether anything else failed.
+type executions struct {
+	retryCount  int
+	retryPolicy RetryPolicy
+
+	mutex     sync.Mutex
+	failed    map[string]bool
+	unretried bool
+}
+
+func newExecutions(cfg *config) *executions {
+	return &executions{
+		retryCount:  cfg.flakyRetryCount,
+		retryPolicy: cfg.flakyRetryPolicy,
+		failed:      map[string]bool{},
+	}
+}
+
+// run must be deferred before the span of the first attempt is started, start being the
+// time the first attempt started.
+func (e *executions) run(t *testing.T, name string, testFn func(*testing.T), start time.Time) {
+	if p := recover(); p != nil {
+		// The testing package ends the test run after a panic, once the session is finished:
+		// the spans of additional attempts would be lost.
+		panic(p)
+	}
+
+	if isNewTest(name, testFn) {
+		passed := detectFlakes(t, name, testFn, time.Since(start))
+		if t.Failed() {
+			e.record(name, passed)
+		}
+	} else if e.retryCount > 0 && t.Failed() {
+		e.record(name, e.retry(t, name, testFn))
+	} else if t.Failed() {
+		e.fail()
+	}
+}
+
+// retry executes a failed test until it passes or the retries are exhausted.
+func (e *executions) retry(t *testing.T, name string, testFn func(*testing.T)) bool {
+	passed := false
+	for i := 1; i <= e.retryCount && !passed; i++ {
+		passed = runAttempt(t, name, testFn, i)
+	}
+	return passed
+}
+
+func (e *executions) record(name string, passed bool) {
+	e.mutex.Lock()
+	e.failed[name] = passed
+	e.mutex.Unlock()
+}
+
+// fail records a failure that isn't retried, such as a failed example, benchmark or fuzz test.
+func (e *executions) fail() {
+	e.mutex.Lock()
+	e.unretried = true
+	e.mutex.Unlock()
+}
+
+// exitCode applies the retry policy to the exit code of the test run, which only passes when
+// all the failures of the run are tests retried until they passed.
+func (e *executions) exitCode(code int) int {
+	if code == 0 || e.retryPolicy != RetryPolicyPass {
+		return code
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if len(e.failed) == 0 || e.unretried {
+		return code
+	}
+	for _, passed := range e.failed {
+		if !passed {
+			return code
+		}
+	}
+	return 0
+}
+
+// runAttempt executes an additional attempt of a test as a subtest, reported with the name
+// of the original test. The test stays failed for the testing package once an attempt failed,
+// so go test and the JUnit report show it as failed even if a retry passes.
+func runAttempt(t *testing.T, name string, testFn func(*testing.T), retryNumber int) bool {
+	return t.Run(fmt.Sprintf("retry_%d", retryNumber), func(t *testing.T) {
+		ctx, finish := ddtesting.StartTestWithContext(GetContext(t), t,
+			ddtesting.WithOriginalTestFunc(testFn),
+			ddtesting.WithTestName(name),
+			ddtesting.WithSpanOptions(
+				tracer.Tag(constants.TestIsRetry, "true"),
+				tracer.Tag(constants.TestRetryNumber, retryNumber),
+			))
+		setContext(t, ctx)
+		defer deleteContext(t)
+		defer finish()
+		testFn(t)
+	})
+}
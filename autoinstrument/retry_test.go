@@ -0,0 +1,82 @@
+package autoinstrument
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+)
+
+func TestExecutionsExitCode(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    RetryPolicy
+		failed    map[string]bool
+		unretried bool
+		code      int
+		expected  int
+	}{
+		{"passed", RetryPolicyPass, map[string]bool{}, false, 0, 0},
+		{"no retries", RetryPolicyPass, map[string]bool{}, false, 1, 1},
+		{"passed on retry", RetryPolicyPass, map[string]bool{"TestA": true, "TestB": true}, false, 1, 0},
+		{"failed on retry", RetryPolicyPass, map[string]bool{"TestA": true, "TestB": false}, false, 1, 1},
+		{"failed without retry", RetryPolicyPass, map[string]bool{"TestA": true}, true, 1, 1},
+		{"fail policy", RetryPolicyFail, map[string]bool{"TestA": true}, false, 1, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := newExecutions(&config{flakyRetryCount: 1, flakyRetryPolicy: test.policy})
+			e.failed = test.failed
+			e.unretried = test.unretried
+			if actual := e.exitCode(test.code); actual != test.expected {
+				t.Fatalf("expected exit code %d, actual %d", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestExecutionsFailedExample(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	e := newExecutions(&config{flakyRetryCount: 1, flakyRetryPolicy: RetryPolicyPass})
+	e.record("TestA", true)
+	eg := testing.InternalExample{Name: "Example_y", F: func() { fmt.Print("x") }, Output: "y"}
+	instrumentExample(eg, e).F()
+	if code := e.exitCode(1); code != 1 {
+		t.Fatalf("expected exit code 1, actual %d", code)
+	}
+}
+
+// TestPanicNotRetried runs a failed test panicking in a child process, which the panic ends,
+// and checks that it isn't retried.
+func TestPanicNotRetried(t *testing.T) {
+	if os.Getenv("DD_SDK_TESTING_PANIC") == "1" {
+		e := newExecutions(&config{flakyRetryCount: 2, flakyRetryPolicy: RetryPolicyPass})
+		testFn := func(t *testing.T) {
+			fmt.Println("attempt")
+			t.Fail()
+			panic("test panic")
+		}
+		t.Run("panic", func(t *testing.T) {
+			defer e.run(t, "TestPanic", testFn, time.Now())
+			testFn(t)
+		})
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestPanicNotRetried$")
+	cmd.Env = append(os.Environ(), "DD_SDK_TESTING_PANIC=1")
+	out, _ := cmd.CombinedOutput()
+	if !strings.Contains(string(out), "test panic") {
+		t.Fatalf("unexpected child output: %s", out)
+	}
+	if attempts := strings.Count(string(out), "attempt\n"); attempts != 1 {
+		t.Fatalf("expected 1 attempt, got %d:\n%s", attempts, out)
+	}
+}
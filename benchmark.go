@@ -0,0 +1,67 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package dd_sdk_go_testing
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/constants"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+)
+
+// setBenchmarkMetrics sets the results of a benchmark run as metrics of its span: the numbers
+// printed by `go test -bench -benchmem` and the metrics reported with b.ReportMetric. The
+// allocations and reported metrics are read from the unexported fields of testing.B, so that
+// they honor ResetTimer, StopTimer and StartTimer like the go test output does.
+func setBenchmarkMetrics(span ddtrace.Span, b *testing.B) {
+	if b.N <= 0 {
+		return
+	}
+	n := float64(b.N)
+	span.SetTag(constants.BenchmarkRuns, b.N)
+
+	if elapsed, ok := benchmarkElapsed(b); ok {
+		span.SetTag(constants.BenchmarkDurationMean, float64(elapsed.Nanoseconds())/n)
+	}
+
+	v := reflect.ValueOf(b).Elem()
+	netAllocs, netBytes := v.FieldByName("netAllocs"), v.FieldByName("netBytes")
+	startAllocs, startBytes := v.FieldByName("startAllocs"), v.FieldByName("startBytes")
+	timerOn := v.FieldByName("timerOn")
+	if netAllocs.Kind() == reflect.Uint64 && netBytes.Kind() == reflect.Uint64 &&
+		startAllocs.Kind() == reflect.Uint64 && startBytes.Kind() == reflect.Uint64 && timerOn.Kind() == reflect.Bool {
+		allocs, bytes := netAllocs.Uint(), netBytes.Uint()
+		if timerOn.Bool() {
+			var memStats runtime.MemStats
+			runtime.ReadMemStats(&memStats)
+			allocs += memStats.Mallocs - startAllocs.Uint()
+			bytes += memStats.TotalAlloc - startBytes.Uint()
+		}
+		span.SetTag(constants.BenchmarkAllocs, float64(allocs)/n)
+		span.SetTag(constants.BenchmarkAllocatedBytes, float64(bytes)/n)
+	}
+
+	if extra := v.FieldByName("extra"); extra.Kind() == reflect.Map {
+		for _, unit := range extra.MapKeys() {
+			span.SetTag(constants.BenchmarkMetricPrefix+unit.String(), extra.MapIndex(unit).Float())
+		}
+	}
+}
+
+// benchmarkElapsed returns the measured time of the benchmark run, from b.Elapsed on Go 1.20 and
+// later, or from the unexported duration of testing.B otherwise.
+func benchmarkElapsed(b *testing.B) (time.Duration, bool) {
+	if e, ok := interface{}(b).(interface{ Elapsed() time.Duration }); ok {
+		return e.Elapsed(), true
+	}
+	if d := reflect.ValueOf(b).Elem().FieldByName("duration"); d.Kind() == reflect.Int64 {
+		return time.Duration(d.Int()), true
+	}
+	return 0, false
+}
@@ -0,0 +1,53 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package dd_sdk_go_testing
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/constants"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+)
+
+var benchmarkSink []byte
+
+func TestBenchmarkMetrics(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	result := testing.Benchmark(func(b *testing.B) {
+		_, finish := StartTest(b)
+		defer finish()
+
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			benchmarkSink = make([]byte, 64)
+			time.Sleep(time.Microsecond)
+		}
+		b.ReportMetric(3, "widgets/op")
+	})
+
+	spans := mt.FinishedSpans()
+	if len(spans) == 0 {
+		t.FailNow()
+	}
+
+	s := spans[len(spans)-1]
+	assertEqual(constants.TestTypeBenchmark, s.Tag(constants.TestType).(string))
+	assertEqual(fmt.Sprint(result.N), fmt.Sprint(s.Tag(constants.BenchmarkRuns)))
+	assertEqual("3", fmt.Sprint(s.Tag(constants.BenchmarkMetricPrefix+"widgets/op")))
+	if mean := s.Tag(constants.BenchmarkDurationMean).(float64); mean < float64(time.Microsecond) {
+		t.Fatalf("unexpected mean duration: %f", mean)
+	}
+	if allocs := s.Tag(constants.BenchmarkAllocs).(float64); allocs < 1 {
+		t.Fatalf("unexpected allocations: %f", allocs)
+	}
+	if bytes := s.Tag(constants.BenchmarkAllocatedBytes).(float64); bytes < 64 {
+		t.Fatalf("unexpected allocated bytes: %f", bytes)
+	}
+}
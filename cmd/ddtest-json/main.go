@@ -0,0 +1,29 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+// Command ddtest-json reports the tests run by go test -json as test spans, without changing
+// the tests:
+//
+//	go test -json ./... | ddtest-json
+//
+// It copies the events read from its standard input to its standard output, and exits with 1
+// if a package failed, as go test does.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	ddtesting "github.com/DataDog/dd-sdk-go-testing"
+)
+
+func main() {
+	exitCode, err := ddtesting.RunJSON(os.Stdin, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ddtest-json: %v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(exitCode)
+}
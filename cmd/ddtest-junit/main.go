@@ -0,0 +1,32 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+// Command ddtest-junit reports the tests of JUnit XML files as test spans, such as the reports
+// written by gotestsum, go-junit-report or the test runners of other languages in the same
+// pipeline:
+//
+//	ddtest-junit report.xml [more.xml ...]
+//
+// The test spans are tagged with the CI and Git metadata of the environment, as the tests
+// traced by the sdk are.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	ddtesting "github.com/DataDog/dd-sdk-go-testing"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: ddtest-junit file.xml [file.xml ...]")
+		os.Exit(2)
+	}
+	if err := ddtesting.ImportJUnit(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ddtest-junit: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,166 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/rewrite"
+)
+
+// testMainFiles are the names of the file of the main package of a test binary generated by go
+// test, and of its copy instrumented for coverage when building with -cover.
+var testMainFiles = map[string]bool{
+	"_testmain.go":       true,
+	"_testmain.cover.go": true,
+}
+
+// compileArgs returns the arguments of a compilation with the test files rewritten to trace
+// their subtests, the test main rewritten to instrument the tests, and the autoinstrument
+// package added to the import configuration.
+func compileArgs(args []string) ([]string, error) {
+	pkg := flagValue(args, "-p")
+	output := flagValue(args, "-o")
+	importcfg := flagValue(args, "-importcfg")
+	if pkg == "" || output == "" || importcfg == "" || !hasTestFiles(args) {
+		return args, nil
+	}
+
+	deps, err := autoinstrumentDeps(workDir(output), args)
+	if err != nil {
+		return nil, err
+	}
+	// canImport reports whether a package can import autoinstrument.
+	canImport := func(pkg string) bool {
+		_, ok := deps[pkg]
+		return !ok && !strings.HasPrefix(pkg, "github.com/DataDog/dd-sdk-go-testing")
+	}
+
+	outDir := filepath.Dir(output)
+	newArgs := make([]string, len(args))
+	copy(newArgs, args)
+	rewritten := false
+	for i, arg := range newArgs {
+		if !isTestFile(arg) {
+			continue
+		}
+		filename, err := filepath.Abs(arg)
+		if err != nil {
+			return nil, err
+		}
+		src, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		var out []byte
+		var ok bool
+		if isTestMain(arg) {
+			if !canImport(rewrite.TestedPackage(src)) {
+				continue
+			}
+			out, ok, err = rewrite.TestMain(filename, src)
+		} else {
+			if !canImport(pkg) {
+				continue
+			}
+			out, ok, err = rewrite.Subtests(filename, src)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		newFile := filepath.Join(outDir, "ddtest_"+filepath.Base(arg))
+		if err := ioutil.WriteFile(newFile, out, 0644); err != nil {
+			return nil, err
+		}
+		newArgs[i] = newFile
+		rewritten = true
+	}
+	if !rewritten {
+		return args, nil
+	}
+
+	newImportcfg, err := addImports(importcfg, deps)
+	if err != nil {
+		return nil, err
+	}
+	setFlagValue(newArgs, "-importcfg", newImportcfg)
+	return newArgs, nil
+}
+
+// linkArgs returns the arguments of the link of a test binary with the dependencies of the
+// autoinstrument package added to the import configuration.
+func linkArgs(args []string) ([]string, error) {
+	output := flagValue(args, "-o")
+	importcfg := flagValue(args, "-importcfg")
+	if importcfg == "" || !strings.HasSuffix(strings.TrimSuffix(output, ".exe"), ".test") {
+		return args, nil
+	}
+
+	deps, err := autoinstrumentDeps(workDir(output), args)
+	if err != nil {
+		return nil, err
+	}
+	newImportcfg, err := addImports(importcfg, deps)
+	if err != nil {
+		return nil, err
+	}
+
+	newArgs := make([]string, len(args))
+	copy(newArgs, args)
+	setFlagValue(newArgs, "-importcfg", newImportcfg)
+	return newArgs, nil
+}
+
+// hasTestFiles reports whether the compiled files include test files or the test main.
+func hasTestFiles(args []string) bool {
+	for _, arg := range args {
+		if isTestFile(arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTestFile reports whether an argument is a test file or the test main generated by go test.
+func isTestFile(arg string) bool {
+	return !strings.HasPrefix(arg, "-") && (strings.HasSuffix(arg, "_test.go") || isTestMain(arg))
+}
+
+// isTestMain reports whether an argument is the test main generated by go test.
+func isTestMain(arg string) bool {
+	return testMainFiles[filepath.Base(arg)]
+}
+
+// flagValue returns the value of a flag given as a separate argument, as the go command does.
+func flagValue(args []string, name string) string {
+	for i := 0; i < len(args)-1; i++ {
+		if args[i] == name {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+func setFlagValue(args []string, name, value string) {
+	for i := 0; i < len(args)-1; i++ {
+		if args[i] == name {
+			args[i+1] = value
+		}
+	}
+}
+
+// workDir returns the work directory of the go command from the path of an output file in
+// the directory of one of its actions, such as $WORK/b001/_pkg_.a.
+func workDir(output string) string {
+	return filepath.Dir(filepath.Dir(output))
+}
@@ -0,0 +1,136 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+//go:build go1.20
+// +build go1.20
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/agentless"
+	"github.com/DataDog/dd-sdk-go-testing/internal/constants"
+)
+
+// coverageModule is a module whose tests each execute different blocks of its source file.
+var coverageModule = map[string]string{
+	"go.mod": `module example.com/coverage
+
+go 1.20
+
+require github.com/DataDog/dd-sdk-go-testing v0.0.0
+`,
+	"coverage.go": `package coverage
+
+func Sign(x int) int {
+	if x > 0 {
+		return 1
+	}
+	return -1
+}
+
+func Zero() int { return 0 }
+`,
+	"coverage_test.go": `package coverage
+
+import "testing"
+
+func TestPositive(t *testing.T) { Sign(1) }
+
+func TestZero(t *testing.T) { Zero() }
+
+func TestNothing(t *testing.T) {}
+`,
+}
+
+func TestCoverage(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a test binary with ddtest-toolexec")
+	}
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "ddtest-toolexec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, content := range coverageModule {
+		if name == "go.mod" {
+			content += "\nreplace github.com/DataDog/dd-sdk-go-testing => " + root + "\n"
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sum, err := ioutil.ReadFile(filepath.Join(root, "go.sum"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.sum"), sum, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	toolexec := filepath.Join(dir, "ddtest-toolexec")
+	if out, err := exec.Command("go", "build", "-o", toolexec, ".").CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+	offline := filepath.Join(dir, "offline")
+	if err := os.Mkdir(offline, 0755); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("go", "test", "-count=1", "-cover", "-covermode=count", "-toolexec="+toolexec, ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GOFLAGS=-mod=mod",
+		"DD_CIVISIBILITY_OFFLINE_DIR="+offline,
+		"DD_CIVISIBILITY_CODE_COVERAGE_ENABLED=true",
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go test: %v\n%s", err, out)
+	}
+
+	files, err := filepath.Glob(filepath.Join(offline, "*"+agentless.OfflineFileExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	covered := map[string]string{}
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			var span agentless.Span
+			if err := json.Unmarshal(s.Bytes(), &span); err != nil {
+				t.Fatal(err)
+			}
+			if span.Type == constants.SpanTypeTest {
+				covered[span.Meta[constants.TestName]] = span.Meta[constants.TestCodeCoverageFiles]
+			}
+		}
+		f.Close()
+	}
+
+	for test, expected := range map[string]string{
+		"TestPositive": `[{"filename":"example.com/coverage/coverage.go","segments":[[4,2,4,11],[5,3,6,1]]}]`,
+		"TestZero":     `[{"filename":"example.com/coverage/coverage.go","segments":[[10,19,10,29]]}]`,
+		"TestNothing":  `[]`,
+	} {
+		if covered[test] != expected {
+			t.Fatalf("unexpected coverage of %s: %s, expected %s", test, covered[test], expected)
+		}
+	}
+}
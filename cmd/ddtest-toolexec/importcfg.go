@@ -0,0 +1,121 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/rewrite"
+)
+
+// depsFile is the file of the work directory caching the dependencies of autoinstrument for
+// the tools executed by the same go command.
+const depsFile = "ddtest-toolexec.deps"
+
+// autoinstrumentDeps returns the export data files of the autoinstrument package and of its
+// dependencies, by import path. They're built by go list with the instrumentation flags of the
+// tool arguments, as the packages of the test binary must be.
+func autoinstrumentDeps(work string, args []string) (map[string]string, error) {
+	var flags []string
+	for _, arg := range args {
+		switch arg {
+		case "-race", "-msan", "-asan":
+			flags = append(flags, arg)
+		}
+	}
+
+	cacheFile := filepath.Join(work, depsFile+strings.Join(flags, ""))
+	out, err := ioutil.ReadFile(cacheFile)
+	if err != nil {
+		goArgs := append([]string{"list", "-export", "-deps", "-f", "{{if .Export}}{{.ImportPath}}={{.Export}}{{end}}"}, flags...)
+		cmd := exec.Command("go", append(goArgs, rewrite.AutoinstrumentPath)...)
+		cmd.Env = append(os.Environ(), "GOFLAGS="+withoutToolexec(os.Getenv("GOFLAGS")))
+		cmd.Stderr = new(bytes.Buffer)
+		out, err = cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("go list %s: %v: %s", rewrite.AutoinstrumentPath, err, cmd.Stderr)
+		}
+
+		// Write the cache atomically as the tools run concurrently.
+		tmp, err := ioutil.TempFile(work, depsFile)
+		if err == nil {
+			tmp.Write(out)
+			tmp.Close()
+			os.Rename(tmp.Name(), cacheFile)
+		}
+	}
+
+	deps := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if i := strings.Index(scanner.Text(), "="); i > 0 {
+			deps[scanner.Text()[:i]] = scanner.Text()[i+1:]
+		}
+	}
+	return deps, scanner.Err()
+}
+
+// withoutToolexec removes the -toolexec flag from GOFLAGS, so that go list doesn't run this
+// program again.
+func withoutToolexec(goflags string) string {
+	var flags []string
+	for _, flag := range strings.Fields(goflags) {
+		if !strings.HasPrefix(flag, "-toolexec") && !strings.HasPrefix(flag, "--toolexec") {
+			flags = append(flags, flag)
+		}
+	}
+	return strings.Join(flags, " ")
+}
+
+// addImports writes a copy of an import configuration with the packages it lacks, next to it,
+// and returns its path.
+func addImports(importcfg string, packages map[string]string) (string, error) {
+	cfg, err := ioutil.ReadFile(importcfg)
+	if err != nil {
+		return "", err
+	}
+
+	known := map[string]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(cfg))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "packagefile ") {
+			if i := strings.Index(line, "="); i > 0 {
+				known[strings.TrimSpace(line[len("packagefile "):i])] = true
+			}
+		}
+	}
+
+	var missing []string
+	for path := range packages {
+		if !known[path] {
+			missing = append(missing, path)
+		}
+	}
+	sort.Strings(missing)
+
+	out := bytes.NewBuffer(cfg)
+	if len(cfg) > 0 && cfg[len(cfg)-1] != '\n' {
+		out.WriteByte('\n')
+	}
+	for _, path := range missing {
+		fmt.Fprintf(out, "packagefile %s=%s\n", path, packages[path])
+	}
+
+	newImportcfg := importcfg + ".ddtest"
+	if err := ioutil.WriteFile(newImportcfg, out.Bytes(), 0644); err != nil {
+		return "", err
+	}
+	return newImportcfg, nil
+}
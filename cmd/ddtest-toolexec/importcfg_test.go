@@ -0,0 +1,51 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAddImports(t *testing.T) {
+	dir, err := ioutil.TempDir("", "importcfg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	importcfg := filepath.Join(dir, "importcfg")
+	cfg := "# import config\npackagefile testing=/cache/testing.a\nimportmap old=new"
+	if err := ioutil.WriteFile(importcfg, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	newImportcfg, err := addImports(importcfg, map[string]string{
+		"testing":       "/other/testing.a",
+		"example.com/b": "/cache/b.a",
+		"example.com/a": "/cache/a.a",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := ioutil.ReadFile(newImportcfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := cfg + "\npackagefile example.com/a=/cache/a.a\npackagefile example.com/b=/cache/b.a\n"
+	if string(out) != expected {
+		t.Fatalf("unexpected import config:\n%s", out)
+	}
+}
+
+func TestWithoutToolexec(t *testing.T) {
+	if flags := withoutToolexec("-mod=vendor -toolexec=ddtest-toolexec -tags=a"); flags != "-mod=vendor -tags=a" {
+		t.Fatalf("unexpected flags: %q", flags)
+	}
+}
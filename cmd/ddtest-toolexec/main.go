@@ -0,0 +1,120 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+// Command ddtest-toolexec instruments the tests of the packages built by go test when used as
+// the -toolexec program:
+//
+//	go test -toolexec=ddtest-toolexec ./...
+//
+// It rewrites the test main generated by go test so that the tests, benchmarks, fuzz tests and
+// examples are instrumented by autoinstrument, as autoinstrument.RunM does, without adding a
+// TestMain function to the tested packages. The tests of the packages defining one are not
+// instrumented, and can call autoinstrument.RunM from it. The test mains of the packages built with
+// -cover also register the coverage profile writer, so that the blocks executed by each test are
+// reported when DD_CIVISIBILITY_CODE_COVERAGE_ENABLED is set. It also rewrites the t.Run calls of the test files into
+// autoinstrument.Run calls when they're compiled, so that the subtests are traced as child spans
+// of their parent test. The module of the tested packages must require
+// github.com/DataDog/dd-sdk-go-testing.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: go test -toolexec=ddtest-toolexec [packages]")
+		os.Exit(2)
+	}
+	tool, args := os.Args[1], os.Args[2:]
+
+	if len(args) == 1 && args[0] == "-V=full" {
+		os.Exit(printVersion(tool))
+	}
+
+	var err error
+	switch toolName(tool) {
+	case "compile":
+		args, err = compileArgs(args)
+	case "link":
+		args, err = linkArgs(args)
+	}
+	if err != nil {
+		// Build the package without instrumentation rather than failing the build.
+		fmt.Fprintf(os.Stderr, "ddtest-toolexec: %v\n", err)
+		args = os.Args[2:]
+	}
+	os.Exit(run(tool, args))
+}
+
+// toolName returns the name of a Go tool from its path.
+func toolName(tool string) string {
+	return strings.TrimSuffix(filepath.Base(tool), ".exe")
+}
+
+// run executes the tool and returns its exit code.
+func run(tool string, args []string) int {
+	cmd := exec.Command(tool, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode()
+		}
+		fmt.Fprintf(os.Stderr, "ddtest-toolexec: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// printVersion prints the version of the tool, which the go command uses as the tool ID in the
+// keys of the build cache. The hash of this executable is added after the Go version so that
+// the instrumented packages are cached apart from the others, keeping the build ID of
+// development versions of Go in the last field.
+func printVersion(tool string) int {
+	out, err := exec.Command(tool, "-V=full").Output()
+	if err != nil {
+		os.Stderr.Write(out)
+		fmt.Fprintf(os.Stderr, "ddtest-toolexec: %v\n", err)
+		return 1
+	}
+
+	fields := strings.Fields(string(out))
+	if len(fields) < 3 {
+		os.Stdout.Write(out)
+		return 0
+	}
+	version := append(fields[:3:3], "ddtest-toolexec="+executableHash())
+	version = append(version, fields[3:]...)
+	fmt.Println(strings.Join(version, " "))
+	return 0
+}
+
+// executableHash returns the hash of this executable.
+func executableHash() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return "unknown"
+	}
+	f, err := os.Open(exe)
+	if err != nil {
+		return "unknown"
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
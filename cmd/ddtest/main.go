@@ -0,0 +1,114 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+// Command ddtest manages the test events of the sdk.
+//
+// The upload command sends the test events written in offline mode, with
+// DD_CIVISIBILITY_OFFLINE_DIR, to the CI Visibility intake in agentless mode, or to the Datadog
+// agent otherwise:
+//
+//	ddtest upload [-agent url] [-keep] [dir or file ...]
+//
+// The arguments default to DD_CIVISIBILITY_OFFLINE_DIR. The files are removed once uploaded,
+// unless -keep is given, so that a failed upload can be retried without sending the same events
+// twice.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/agentless"
+)
+
+// uploader uploads the files written in offline mode.
+type uploader interface {
+	UploadFile(path string) error
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "upload" {
+		fmt.Fprintln(os.Stderr, "usage: ddtest upload [-agent url] [-keep] [dir or file ...]")
+		os.Exit(2)
+	}
+	if err := upload(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ddtest: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func upload(args []string) error {
+	fs := flag.NewFlagSet("upload", flag.ExitOnError)
+	agentURL := fs.String("agent", "", "URL of the Datadog agent (default from DD_TRACE_AGENT_URL or DD_AGENT_HOST)")
+	keep := fs.Bool("keep", false, "keep the files once uploaded")
+	fs.Parse(args)
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		dir := agentless.OfflineDir()
+		if dir == "" {
+			return fmt.Errorf("no files to upload: DD_CIVISIBILITY_OFFLINE_DIR is not set")
+		}
+		paths = []string{dir}
+	}
+	files, err := offlineFiles(paths)
+	if err != nil {
+		return err
+	}
+
+	var u uploader
+	if agentless.Enabled() && *agentURL == "" {
+		u = agentless.NewTransport(agentless.URL(), os.Getenv("DD_API_KEY"))
+	} else {
+		if *agentURL == "" {
+			*agentURL = agentless.AgentURL()
+		}
+		u = agentless.NewAgentClient(*agentURL)
+	}
+
+	for _, file := range files {
+		if err := u.UploadFile(file); err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+		if !*keep {
+			if err := os.Remove(file); err != nil {
+				return err
+			}
+		}
+	}
+	fmt.Fprintf(os.Stderr, "ddtest: uploaded %d files\n", len(files))
+	return nil
+}
+
+// offlineFiles returns the files given as arguments and the files written in offline mode in the
+// directories given as arguments.
+func offlineFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.HasSuffix(entry.Name(), agentless.OfflineFileExt) {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,150 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package dd_sdk_go_testing
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/agentless"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// Exporter starts the spans of the test events and sends them once finished. The spans of the
+// sessions, modules, suites and tests are started with the span options of dd-trace-go, which
+// implementations apply to a ddtrace.StartSpanConfig to read their start time, parent and tags.
+type Exporter interface {
+	// Start is called before the first span is started.
+	Start()
+
+	// StartSpan starts a span with the given operation name.
+	StartSpan(operationName string, opts ...ddtrace.StartSpanOption) ddtrace.Span
+
+	// Flush sends the spans finished so far.
+	Flush()
+
+	// Stop sends the remaining spans and releases the resources of the exporter.
+	Stop()
+}
+
+var (
+	// exporter is the exporter started by the last call to Run, RunJSON or ImportJUnit.
+	exporter Exporter
+
+	// selectedExporter is the exporter set with SetExporter.
+	selectedExporter Exporter
+
+	exporterMutex sync.RWMutex
+)
+
+// SetExporter sets the exporter started by Run, RunJSON and ImportJUnit to send the test events
+// instead of the global tracer of dd-trace-go, in which case their options are ignored. A nil
+// exporter restores the global tracer.
+//
+//	func TestMain(m *testing.M) {
+//		ddtesting.SetExporter(ddtesting.NewOTLPExporter(""))
+//		os.Exit(ddtesting.Run(m))
+//	}
+func SetExporter(e Exporter) {
+	exporterMutex.Lock()
+	defer exporterMutex.Unlock()
+	selectedExporter = e
+}
+
+// startExporter preloads the CI tags and test settings, then starts the exporter set with
+// SetExporter, the isolated exporter if enabled, or the tracer started with opts otherwise. It
+// returns the function flushing and stopping it.
+func startExporter(opts ...tracer.StartOption) func() {
+	// Preload all CI and Git tags.
+	ensureCITags()
+
+	// Preload test settings.
+	ensureSettings()
+
+	exporterMutex.Lock()
+	e := selectedExporter
+	if e == nil && isolatedEnabled() {
+		e = NewIsolatedExporter()
+	}
+	if e == nil {
+		e = NewTracerExporter(opts...)
+	}
+	exporter = e
+	exporterMutex.Unlock()
+
+	e.Start()
+	return func() {
+		e.Flush()
+		e.Stop()
+	}
+}
+
+// getExporter returns the exporter selected by Run, or the global tracer if Run was not used.
+func getExporter() Exporter {
+	exporterMutex.RLock()
+	defer exporterMutex.RUnlock()
+	if exporter == nil {
+		return tracerExporter{}
+	}
+	return exporter
+}
+
+// startSpanFromContext starts a span with the exporter, as a child of the span of ctx if any,
+// and returns it with a context holding it.
+func startSpanFromContext(ctx context.Context, operationName string, opts ...ddtrace.StartSpanOption) (ddtrace.Span, context.Context) {
+	if parent, ok := tracer.SpanFromContext(ctx); ok {
+		opts = append(opts, tracer.ChildOf(parent.Context()))
+	}
+	span := getExporter().StartSpan(operationName, opts...)
+	return span, tracer.ContextWithSpan(ctx, span)
+}
+
+// tracerExporter sends the test events with the global tracer of dd-trace-go.
+type tracerExporter struct {
+	opts []tracer.StartOption
+}
+
+// NewTracerExporter returns the exporter sending the test events with the global tracer of
+// dd-trace-go, started with the given options. It's used unless Run is given another exporter.
+func NewTracerExporter(opts ...tracer.StartOption) Exporter {
+	return tracerExporter{opts: opts}
+}
+
+// Start sets the service name and transport of the test events, and starts the tracer.
+func (e tracerExporter) Start() {
+	opts := e.opts
+
+	// Check if DD_SERVICE has been set; otherwise we default to repo name.
+	if v := os.Getenv("DD_SERVICE"); v == "" {
+		if service, ok := getServiceName(); ok {
+			opts = append(opts, tracer.WithService(service))
+		}
+	}
+
+	// Write the test events to local files in offline mode, or send them straight to the intake
+	// when running without an agent.
+	if agentless.OfflineDir() != "" {
+		opts = append(opts, tracer.WithHTTPClient(agentless.NewOfflineHTTPClient()))
+	} else if agentless.Enabled() {
+		opts = append(opts, tracer.WithHTTPClient(agentless.NewHTTPClient()))
+	}
+
+	tracer.Start(opts...)
+}
+
+func (tracerExporter) StartSpan(operationName string, opts ...ddtrace.StartSpanOption) ddtrace.Span {
+	return tracer.StartSpan(operationName, opts...)
+}
+
+func (tracerExporter) Flush() {
+	tracer.Flush()
+}
+
+func (tracerExporter) Stop() {
+	tracer.Stop()
+}
@@ -0,0 +1,220 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package dd_sdk_go_testing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+)
+
+const (
+	defaultOTLPEndpoint = "http://localhost:4318"
+	otlpTracesPath      = "/v1/traces"
+
+	// otlpScopeName is the instrumentation scope of the spans sent with OTLP.
+	otlpScopeName = "github.com/DataDog/dd-sdk-go-testing"
+
+	otlpSpanKindInternal = 1
+	otlpStatusCodeError  = 2
+)
+
+// otlpExporter posts the spans to an OpenTelemetry collector with OTLP over HTTP, encoded as
+// JSON.
+type otlpExporter struct {
+	batcher *spanBatcher
+	url     string
+	client  *http.Client
+	service string
+}
+
+// NewOTLPExporter returns an exporter posting the test events to the traces endpoint of an
+// OpenTelemetry collector with OTLP/HTTP. An empty url defaults to
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, or to the /v1/traces path of OTEL_EXPORTER_OTLP_ENDPOINT
+// or http://localhost:4318 otherwise.
+func NewOTLPExporter(url string) Exporter {
+	if url == "" {
+		url = os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	}
+	if url == "" {
+		endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if endpoint == "" {
+			endpoint = defaultOTLPEndpoint
+		}
+		url = strings.TrimSuffix(endpoint, "/") + otlpTracesPath
+	}
+	e := &otlpExporter{
+		url:    url,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+	e.batcher = newSpanBatcher(e.send)
+	return e
+}
+
+// Start implements Exporter.
+func (e *otlpExporter) Start() {
+	e.service, _ = getServiceName()
+	e.batcher.start()
+}
+
+// StartSpan implements Exporter.
+func (e *otlpExporter) StartSpan(operationName string, opts ...ddtrace.StartSpanOption) ddtrace.Span {
+	return e.batcher.recorder.StartSpan(operationName, opts...)
+}
+
+// Flush implements Exporter.
+func (e *otlpExporter) Flush() {
+	e.batcher.flush()
+}
+
+// Stop implements Exporter.
+func (e *otlpExporter) Stop() {
+	e.batcher.close()
+}
+
+// send posts a batch of spans.
+func (e *otlpExporter) send(spans []*RecordedSpan) {
+	if err := e.post(spans); err != nil {
+		log.Printf("dd-sdk-go-testing: unable to send the test events: %v", err)
+	}
+}
+
+// post sends the spans in an ExportTraceServiceRequest.
+func (e *otlpExporter) post(spans []*RecordedSpan) error {
+	body, err := json.Marshal(otlpRequest(spans, e.service))
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	msg, _ := ioutil.ReadAll(resp.Body)
+	return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(msg))
+}
+
+// The types of the JSON encoding of the OTLP ExportTraceServiceRequest message.
+type (
+	otlpTraces struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes"`
+		Status            otlpStatus     `json:"status"`
+	}
+
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+
+	otlpKeyValue struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+
+	otlpAnyValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+)
+
+// otlpRequest returns the request sending the spans of a service. The 64-bit trace ids of the
+// spans are the low bits of their OTLP trace id.
+func otlpRequest(spans []*RecordedSpan, service string) otlpTraces {
+	scope := otlpScopeSpans{Scope: otlpScope{Name: otlpScopeName}}
+	for _, s := range spans {
+		span := otlpSpan{
+			TraceID:           fmt.Sprintf("%032x", s.TraceID()),
+			SpanID:            fmt.Sprintf("%016x", s.SpanID()),
+			Name:              s.OperationName(),
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.StartTime().UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.FinishTime().UnixNano(), 10),
+		}
+		if s.ParentID() != 0 {
+			span.ParentSpanID = fmt.Sprintf("%016x", s.ParentID())
+		}
+
+		tags := s.Tags()
+		if failed, _ := tags[ext.Error].(bool); failed {
+			span.Status = otlpStatus{Code: otlpStatusCodeError, Message: fmt.Sprint(tags[ext.ErrorMsg])}
+		}
+		for k, v := range tags {
+			span.Attributes = append(span.Attributes, otlpAttribute(k, v))
+		}
+		sort.Slice(span.Attributes, func(i, j int) bool { return span.Attributes[i].Key < span.Attributes[j].Key })
+		scope.Spans = append(scope.Spans, span)
+	}
+
+	resource := otlpResource{Attributes: []otlpKeyValue{
+		otlpAttribute("service.name", service),
+		otlpAttribute("telemetry.sdk.language", "go"),
+	}}
+	return otlpTraces{ResourceSpans: []otlpResourceSpans{{Resource: resource, ScopeSpans: []otlpScopeSpans{scope}}}}
+}
+
+// otlpAttribute returns the attribute of a tag, typed as the value.
+func otlpAttribute(key string, value interface{}) otlpKeyValue {
+	var v otlpAnyValue
+	switch value := value.(type) {
+	case bool:
+		v.BoolValue = &value
+	case float32, float64:
+		f, _ := toFloat64(value)
+		v.DoubleValue = &f
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		i := fmt.Sprint(value)
+		v.IntValue = &i
+	default:
+		s := fmt.Sprint(value)
+		v.StringValue = &s
+	}
+	return otlpKeyValue{Key: key, Value: v}
+}
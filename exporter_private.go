@@ -0,0 +1,268 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package dd_sdk_go_testing
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/agentless"
+	"github.com/DataDog/dd-sdk-go-testing/internal/utils"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+)
+
+// samplingPriorityKey is the metric holding the sampling priority of the spans sent by the tracer.
+const samplingPriorityKey = "_sampling_priority_v1"
+
+// isolatedTracerEnv enables the isolated exporter in place of the global tracer.
+const isolatedTracerEnv = "DD_CIVISIBILITY_ISOLATED_TRACER_ENABLED"
+
+const (
+	// flushSpanCount is the number of finished spans sent at once by the exporters holding them.
+	flushSpanCount = 1000
+
+	// flushInterval is the interval at which the exporters holding the finished spans send them,
+	// as the tracer does.
+	flushInterval = 2 * time.Second
+)
+
+// spanBatcher holds the spans finished by a recorder and sends them in batches, once
+// flushSpanCount spans are finished or every flushInterval, instead of keeping them in memory
+// until the exporter is flushed.
+type spanBatcher struct {
+	recorder *Recorder
+	send     func(spans []*RecordedSpan)
+
+	// sendMutex serializes the batches.
+	sendMutex sync.Mutex
+	full      chan struct{}
+	stop      chan struct{}
+	stopOnce  sync.Once
+	done      chan struct{}
+}
+
+func newSpanBatcher(send func(spans []*RecordedSpan)) *spanBatcher {
+	b := &spanBatcher{
+		recorder: NewRecorder(),
+		send:     send,
+		full:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+	}
+	b.recorder.onFinish = func(finished int) {
+		if finished < flushSpanCount {
+			return
+		}
+		select {
+		case b.full <- struct{}{}:
+		default:
+		}
+	}
+	return b
+}
+
+// start starts sending the batches in the background.
+func (b *spanBatcher) start() {
+	b.done = make(chan struct{})
+	go func() {
+		defer close(b.done)
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-b.full:
+			case <-ticker.C:
+			case <-b.stop:
+				return
+			}
+			b.flush()
+		}
+	}()
+}
+
+// flush sends the spans finished so far.
+func (b *spanBatcher) flush() {
+	b.sendMutex.Lock()
+	defer b.sendMutex.Unlock()
+	for {
+		spans := b.recorder.take()
+		if len(spans) == 0 {
+			return
+		}
+		for len(spans) > 0 {
+			n := len(spans)
+			if n > flushSpanCount {
+				n = flushSpanCount
+			}
+			b.send(spans[:n])
+			spans = spans[n:]
+		}
+	}
+}
+
+// close stops sending the batches in the background and sends the remaining spans.
+func (b *spanBatcher) close() {
+	b.stopOnce.Do(func() { close(b.stop) })
+	if b.done != nil {
+		<-b.done
+	}
+	b.flush()
+}
+
+// spanSender sends the spans converted by a private exporter.
+type spanSender interface {
+	Send(spans []*agentless.Span) error
+}
+
+// privateExporter sends the spans with a sender of its own instead of the global tracer, so that
+// the code under test can start and stop the global tracer without affecting the test events.
+type privateExporter struct {
+	batcher *spanBatcher
+	sender  spanSender
+	service string
+}
+
+// NewIsolatedExporter returns an exporter sending the test events as the global tracer would,
+// to the agent, to the intake in agentless mode or to files in offline mode, but with a tracer of
+// its own. The code under test can start and stop the global tracer, whose spans are still
+// children of the test spans. It's used by Run when DD_CIVISIBILITY_ISOLATED_TRACER_ENABLED is
+// true.
+func NewIsolatedExporter() Exporter {
+	var sender spanSender
+	if dir := agentless.OfflineDir(); dir != "" {
+		sender = agentless.NewFileTransport(dir, agentless.MaxFileSize())
+	} else if agentless.Enabled() {
+		sender = agentless.NewTransport(agentless.URL(), os.Getenv("DD_API_KEY"))
+	} else {
+		sender = agentless.NewAgentClient(agentless.AgentURL())
+	}
+	return newPrivateExporter(sender)
+}
+
+// NewFileExporter returns an exporter writing the test events to files of dir, rotated after
+// DD_CIVISIBILITY_OFFLINE_MAX_FILE_SIZE bytes, as in offline mode. The files can be uploaded
+// with ddtest upload.
+func NewFileExporter(dir string) Exporter {
+	return newPrivateExporter(agentless.NewFileTransport(dir, agentless.MaxFileSize()))
+}
+
+func newPrivateExporter(sender spanSender) *privateExporter {
+	e := &privateExporter{sender: sender}
+	e.batcher = newSpanBatcher(e.send)
+	return e
+}
+
+// isolatedEnabled reports whether the isolated exporter is enabled.
+func isolatedEnabled() bool {
+	return utils.BoolEnv(isolatedTracerEnv, false)
+}
+
+// Start implements Exporter.
+func (e *privateExporter) Start() {
+	e.service, _ = getServiceName()
+	e.batcher.start()
+}
+
+// StartSpan implements Exporter.
+func (e *privateExporter) StartSpan(operationName string, opts ...ddtrace.StartSpanOption) ddtrace.Span {
+	return e.batcher.recorder.StartSpan(operationName, opts...)
+}
+
+// Flush implements Exporter.
+func (e *privateExporter) Flush() {
+	e.batcher.flush()
+}
+
+// Stop implements Exporter.
+func (e *privateExporter) Stop() {
+	e.batcher.close()
+}
+
+// send converts and sends a batch of spans.
+func (e *privateExporter) send(recorded []*RecordedSpan) {
+	spans := make([]*agentless.Span, 0, len(recorded))
+	for _, s := range recorded {
+		spans = append(spans, agentlessSpan(s, e.service))
+	}
+	if err := e.sender.Send(spans); err != nil {
+		log.Printf("dd-sdk-go-testing: unable to send the test events: %v", err)
+	}
+}
+
+// agentlessSpan converts a recorded span to a span of the tracer, with the service of the
+// exporter unless the span sets its own. The tags are stored as the tracer does: numbers as
+// metrics, and everything else as strings.
+func agentlessSpan(s *RecordedSpan, service string) *agentless.Span {
+	span := &agentless.Span{
+		Name:     s.OperationName(),
+		Service:  service,
+		Resource: s.OperationName(),
+		Start:    s.StartTime().UnixNano(),
+		Duration: int64(s.FinishTime().Sub(s.StartTime())),
+		Meta:     map[string]string{},
+		Metrics:  map[string]float64{},
+		SpanID:   s.SpanID(),
+		TraceID:  s.TraceID(),
+		ParentID: s.ParentID(),
+	}
+	for k, v := range s.Tags() {
+		switch k {
+		case ext.ServiceName:
+			span.Service = fmt.Sprint(v)
+		case ext.ResourceName:
+			span.Resource = fmt.Sprint(v)
+		case ext.SpanType:
+			span.Type = fmt.Sprint(v)
+		case ext.Error:
+			if failed, _ := v.(bool); failed {
+				span.Error = 1
+			}
+		case ext.ManualKeep:
+			span.Metrics[samplingPriorityKey] = 2
+		default:
+			if f, ok := toFloat64(v); ok {
+				span.Metrics[k] = f
+			} else {
+				span.Meta[k] = fmt.Sprint(v)
+			}
+		}
+	}
+	return span
+}
+
+// toFloat64 returns the value of a numeric tag.
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
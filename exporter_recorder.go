@@ -0,0 +1,287 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package dd_sdk_go_testing
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/constants"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// originHeader is the header propagating the origin of a trace.
+const originHeader = "x-datadog-origin"
+
+var (
+	// propagator creates the span contexts of the recorded spans.
+	propagator = tracer.NewPropagator(nil)
+
+	// spanIDs generates the ids of the recorded spans.
+	spanIDs      = rand.New(rand.NewSource(time.Now().UnixNano()))
+	spanIDsMutex sync.Mutex
+)
+
+// Recorder is an exporter keeping the finished spans in memory, to check the test events in
+// unit tests without a tracer.
+type Recorder struct {
+	mutex    sync.Mutex
+	finished []*RecordedSpan
+
+	// onFinish is called with the number of spans held once a span finishes, if set.
+	onFinish func(finished int)
+}
+
+// NewRecorder returns an exporter recording the spans in memory.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Start implements Exporter.
+func (r *Recorder) Start() {}
+
+// StartSpan implements Exporter.
+func (r *Recorder) StartSpan(operationName string, opts ...ddtrace.StartSpanOption) ddtrace.Span {
+	cfg := ddtrace.StartSpanConfig{}
+	for _, fn := range opts {
+		fn(&cfg)
+	}
+
+	s := &RecordedSpan{
+		recorder: r,
+		name:     operationName,
+		tags:     map[string]interface{}{},
+		start:    cfg.StartTime,
+		spanID:   cfg.SpanID,
+	}
+	if s.start.IsZero() {
+		s.start = time.Now()
+	}
+	if s.spanID == 0 {
+		s.spanID = newSpanID()
+	}
+	s.traceID = s.spanID
+	if cfg.Parent != nil {
+		s.traceID = cfg.Parent.TraceID()
+		s.parentID = cfg.Parent.SpanID()
+	}
+	s.context = propagatedContext(s.traceID, s.spanID)
+	for k, v := range cfg.Tags {
+		s.SetTag(k, v)
+	}
+	return s
+}
+
+// Flush implements Exporter.
+func (r *Recorder) Flush() {}
+
+// Stop implements Exporter.
+func (r *Recorder) Stop() {}
+
+// FinishedSpans returns the spans finished so far, in the order they finished.
+func (r *Recorder) FinishedSpans() []*RecordedSpan {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]*RecordedSpan(nil), r.finished...)
+}
+
+// Reset forgets the spans finished so far.
+func (r *Recorder) Reset() {
+	r.take()
+}
+
+// take returns the spans finished so far and forgets them.
+func (r *Recorder) take() []*RecordedSpan {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	spans := r.finished
+	r.finished = nil
+	return spans
+}
+
+// RecordedSpan is a span started by a Recorder.
+type RecordedSpan struct {
+	recorder *Recorder
+
+	mutex    sync.RWMutex
+	name     string
+	tags     map[string]interface{}
+	start    time.Time
+	end      time.Time
+	spanID   uint64
+	traceID  uint64
+	parentID uint64
+	context  ddtrace.SpanContext
+	finished bool
+}
+
+// SetTag implements ddtrace.Span. An error value of the error tag sets the error message and
+// type, as the tracer does.
+func (s *RecordedSpan) SetTag(key string, value interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if key == ext.Error {
+		switch v := value.(type) {
+		case error:
+			s.tags[ext.Error] = true
+			s.tags[ext.ErrorMsg] = v.Error()
+			s.tags[ext.ErrorType] = fmt.Sprintf("%T", v)
+			return
+		case nil:
+			value = false
+		}
+	}
+	s.tags[key] = value
+}
+
+// SetOperationName implements ddtrace.Span.
+func (s *RecordedSpan) SetOperationName(operationName string) {
+	s.mutex.Lock()
+	s.name = operationName
+	s.mutex.Unlock()
+}
+
+// BaggageItem implements ddtrace.Span. Recorded spans have no baggage.
+func (s *RecordedSpan) BaggageItem(key string) string {
+	return ""
+}
+
+// SetBaggageItem implements ddtrace.Span. Recorded spans have no baggage.
+func (s *RecordedSpan) SetBaggageItem(key, val string) {}
+
+// Finish implements ddtrace.Span, and records the span the first time it's called.
+func (s *RecordedSpan) Finish(opts ...ddtrace.FinishOption) {
+	cfg := ddtrace.FinishConfig{}
+	for _, fn := range opts {
+		fn(&cfg)
+	}
+	if cfg.Error != nil {
+		s.SetTag(ext.Error, cfg.Error)
+	}
+
+	s.mutex.Lock()
+	if s.finished {
+		s.mutex.Unlock()
+		return
+	}
+	s.finished = true
+	s.end = cfg.FinishTime
+	if s.end.IsZero() {
+		s.end = time.Now()
+	}
+	s.mutex.Unlock()
+
+	s.recorder.mutex.Lock()
+	s.recorder.finished = append(s.recorder.finished, s)
+	finished := len(s.recorder.finished)
+	s.recorder.mutex.Unlock()
+
+	if s.recorder.onFinish != nil {
+		s.recorder.onFinish(finished)
+	}
+}
+
+// Context implements ddtrace.Span. The context is a span context of dd-trace-go, so that the spans
+// started by the global tracer in the code under test are children of the recorded span.
+func (s *RecordedSpan) Context() ddtrace.SpanContext {
+	return s.context
+}
+
+// OperationName returns the operation name of the span.
+func (s *RecordedSpan) OperationName() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.name
+}
+
+// Tag returns the value of a tag of the span, or nil if it's not set.
+func (s *RecordedSpan) Tag(key string) interface{} {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.tags[key]
+}
+
+// Tags returns a copy of the tags of the span.
+func (s *RecordedSpan) Tags() map[string]interface{} {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	tags := make(map[string]interface{}, len(s.tags))
+	for k, v := range s.tags {
+		tags[k] = v
+	}
+	return tags
+}
+
+// StartTime returns the start time of the span.
+func (s *RecordedSpan) StartTime() time.Time {
+	return s.start
+}
+
+// FinishTime returns the finish time of the span, or the zero time until it finishes.
+func (s *RecordedSpan) FinishTime() time.Time {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.end
+}
+
+// SpanID returns the id of the span.
+func (s *RecordedSpan) SpanID() uint64 {
+	return s.spanID
+}
+
+// TraceID returns the id of the trace of the span.
+func (s *RecordedSpan) TraceID() uint64 {
+	return s.traceID
+}
+
+// ParentID returns the id of the parent of the span, or 0 if it has none.
+func (s *RecordedSpan) ParentID() uint64 {
+	return s.parentID
+}
+
+// propagatedContext returns the span context of dd-trace-go of a recorded span, extracted as the
+// context of a distributed trace, which the global tracer accepts as a parent. The children are
+// kept and tagged with the origin of the test spans.
+func propagatedContext(traceID, spanID uint64) ddtrace.SpanContext {
+	ctx, err := propagator.Extract(tracer.TextMapCarrier{
+		tracer.DefaultTraceIDHeader:  strconv.FormatUint(traceID, 10),
+		tracer.DefaultParentIDHeader: strconv.FormatUint(spanID, 10),
+		tracer.DefaultPriorityHeader: strconv.Itoa(ext.PriorityUserKeep),
+		originHeader:                 constants.CIAppTestOrigin,
+	})
+	if err != nil {
+		return recordedSpanContext{spanID: spanID, traceID: traceID}
+	}
+	return ctx
+}
+
+// recordedSpanContext is the context of a recorded span, if the propagator fails.
+type recordedSpanContext struct {
+	spanID  uint64
+	traceID uint64
+}
+
+func (c recordedSpanContext) SpanID() uint64 {
+	return c.spanID
+}
+
+func (c recordedSpanContext) TraceID() uint64 {
+	return c.traceID
+}
+
+func (c recordedSpanContext) ForeachBaggageItem(handler func(k, v string) bool) {}
+
+// newSpanID returns a random span id.
+func newSpanID() uint64 {
+	spanIDsMutex.Lock()
+	defer spanIDsMutex.Unlock()
+	return spanIDs.Uint64()
+}
@@ -0,0 +1,304 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package dd_sdk_go_testing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/agentless"
+	"github.com/DataDog/dd-sdk-go-testing/internal/constants"
+	"github.com/tinylib/msgp/msgp"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// useExporter starts e, or the exporter selected by default if nil, in place of the exporter of
+// Run, and returns the function stopping it and restoring the exporter of Run.
+func useExporter(e Exporter) func() {
+	exporterMutex.RLock()
+	previous, previousSelected := exporter, selectedExporter
+	exporterMutex.RUnlock()
+
+	SetExporter(e)
+	stop := startExporter(tracer.WithService("ignored"))
+	return func() {
+		stop()
+		exporterMutex.Lock()
+		exporter, selectedExporter = previous, previousSelected
+		exporterMutex.Unlock()
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	rec := NewRecorder()
+	defer useExporter(rec)()
+	if getExporter() != rec {
+		t.Fatal("the recorder is not the exporter")
+	}
+
+	t.Run("parent", func(t *testing.T) {
+		ctx, finish := StartTest(t)
+		defer finish()
+
+		span, _ := tracer.SpanFromContext(ctx)
+		span.SetTag("k", "v")
+
+		_, finishChild := StartTestWithContext(ctx, t, WithTestName("nested"))
+		finishChild()
+	})
+
+	spans := rec.FinishedSpans()
+	if len(spans) != 2 {
+		t.Fatalf("unexpected spans: %d", len(spans))
+	}
+	nested, parent := spans[0], spans[1]
+	assertEqual("test", parent.OperationName())
+	assertEqual("TestRecorder/parent", parent.Tag(constants.TestName).(string))
+	assertEqual(constants.TestStatusPass, parent.Tag(constants.TestStatus).(string))
+	assertEqual("v", parent.Tag("k").(string))
+	assertEqual("nested", nested.Tag(constants.TestName).(string))
+	if nested.ParentID() != parent.SpanID() || nested.TraceID() != parent.TraceID() {
+		t.Fatal("the nested test is not a child of its parent")
+	}
+	if parent.FinishTime().Before(parent.StartTime()) {
+		t.Fatal("unexpected finish time")
+	}
+
+	rec.Reset()
+	if len(rec.FinishedSpans()) != 0 {
+		t.Fatal("the recorder was not reset")
+	}
+}
+
+func TestRecordedSpanError(t *testing.T) {
+	rec := NewRecorder()
+	span := rec.StartSpan("op", tracer.ResourceName("res"))
+	span.Finish(tracer.WithError(errors.New("boom")))
+	span.Finish()
+
+	spans := rec.FinishedSpans()
+	if len(spans) != 1 {
+		t.Fatalf("unexpected spans: %d", len(spans))
+	}
+	assertEqual("res", spans[0].Tag(ext.ResourceName).(string))
+	assertEqual("boom", spans[0].Tag(ext.ErrorMsg).(string))
+	if spans[0].Tag(ext.Error) != true {
+		t.Fatal("the span is not an error")
+	}
+}
+
+func TestFileExporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	e := NewFileExporter(dir)
+	e.Start()
+	span := e.StartSpan(constants.SpanTypeTest,
+		tracer.SpanType(constants.SpanTypeTest),
+		tracer.ResourceName("pkg.TestA"),
+		tracer.Tag(constants.TestName, "TestA"),
+		tracer.Tag(constants.TestSourceStartLine, 12),
+		tracer.Tag(ext.ManualKeep, true),
+	)
+	span.SetTag(ext.Error, true)
+	span.Finish()
+	e.Stop()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.ndjson"))
+	if len(files) != 1 {
+		t.Fatalf("unexpected files: %v", files)
+	}
+	data, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var line struct {
+		Name     string
+		Resource string
+		Type     string
+		Error    int32
+		Meta     map[string]string
+		Metrics  map[string]float64
+	}
+	if err := json.Unmarshal(data, &line); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual("pkg.TestA", line.Resource)
+	assertEqual(constants.SpanTypeTest, line.Type)
+	assertEqual("TestA", line.Meta[constants.TestName])
+	if line.Error != 1 || line.Metrics[constants.TestSourceStartLine] != 12 || line.Metrics[samplingPriorityKey] != 2 {
+		t.Fatalf("unexpected span: %s", data)
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var request otlpTraces
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != otlpTracesPath || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+	}))
+	defer server.Close()
+
+	e := NewOTLPExporter(server.URL + otlpTracesPath)
+	e.Start()
+	parent := e.StartSpan("test_session_end")
+	child := e.StartSpan(constants.SpanTypeTest,
+		tracer.ChildOf(parent.Context()),
+		tracer.Tag(constants.TestName, "TestA"),
+		tracer.Tag(constants.TestSourceStartLine, 12),
+	)
+	child.SetTag(ext.Error, errors.New("boom"))
+	child.Finish()
+	parent.Finish()
+	e.Stop()
+
+	if len(request.ResourceSpans) != 1 || len(request.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected request: %+v", request)
+	}
+	spans := request.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("unexpected spans: %+v", spans)
+	}
+	test := spans[0]
+	assertEqual(constants.SpanTypeTest, test.Name)
+	assertEqual(spans[1].SpanID, test.ParentSpanID)
+	assertEqual(spans[1].TraceID, test.TraceID)
+	if len(test.TraceID) != 32 || !strings.HasPrefix(test.TraceID, strings.Repeat("0", 16)) {
+		t.Fatalf("unexpected trace id: %s", test.TraceID)
+	}
+	if test.Status.Code != otlpStatusCodeError || test.Status.Message != "boom" {
+		t.Fatalf("unexpected status: %+v", test.Status)
+	}
+
+	attributes := map[string]otlpAnyValue{}
+	for _, kv := range test.Attributes {
+		attributes[kv.Key] = kv.Value
+	}
+	if v := attributes[constants.TestName].StringValue; v == nil || *v != "TestA" {
+		t.Fatalf("unexpected test name: %v", v)
+	}
+	if v := attributes[constants.TestSourceStartLine].IntValue; v == nil || *v != "12" {
+		t.Fatalf("unexpected start line: %v", v)
+	}
+}
+
+func TestIsolatedExporter(t *testing.T) {
+	var mutex sync.Mutex
+	spans := map[string]map[string]interface{}{}
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, err := msgp.NewReader(r.Body).ReadIntf()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, trace := range payload.([]interface{}) {
+			for _, span := range trace.([]interface{}) {
+				span := span.(map[string]interface{})
+				spans[span["name"].(string)] = span
+			}
+		}
+	}))
+	defer agent.Close()
+
+	os.Setenv("DD_TRACE_AGENT_URL", agent.URL)
+	os.Setenv(isolatedTracerEnv, "true")
+	defer os.Unsetenv("DD_TRACE_AGENT_URL")
+	defer os.Unsetenv(isolatedTracerEnv)
+
+	stop := useExporter(nil)
+	if _, ok := getExporter().(*privateExporter); !ok {
+		t.Fatal("the isolated exporter is not the exporter")
+	}
+
+	t.Run("app", func(t *testing.T) {
+		ctx, finish := StartTest(t)
+		defer finish()
+
+		// The code under test starts and stops the global tracer.
+		tracer.Start(tracer.WithAgentAddr(strings.TrimPrefix(agent.URL, "http://")))
+		span, _ := tracer.StartSpanFromContext(ctx, "app")
+		span.Finish()
+		tracer.Stop()
+	})
+	stop()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	test, app := spans[constants.SpanTypeTest], spans["app"]
+	if test == nil || app == nil {
+		t.Fatalf("unexpected spans: %v", spans)
+	}
+	assertEqual("TestIsolatedExporter/app", test["meta"].(map[string]interface{})[constants.TestName].(string))
+	assertEqual(fmt.Sprint(test["trace_id"]), fmt.Sprint(app["trace_id"]))
+	assertEqual(fmt.Sprint(test["span_id"]), fmt.Sprint(app["parent_id"]))
+	assertEqual(constants.CIAppTestOrigin, app["meta"].(map[string]interface{})[constants.Origin].(string))
+}
+
+// countingSender counts the spans and batches sent by a private exporter.
+type countingSender struct {
+	mutex   sync.Mutex
+	spans   int
+	batches int
+}
+
+func (s *countingSender) Send(spans []*agentless.Span) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.spans += len(spans)
+	s.batches++
+	return nil
+}
+
+func (s *countingSender) count() (int, int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.spans, s.batches
+}
+
+func TestPrivateExporterBatches(t *testing.T) {
+	sender := &countingSender{}
+	e := newPrivateExporter(sender)
+	e.Start()
+	for i := 0; i < flushSpanCount+10; i++ {
+		e.StartSpan(constants.SpanTypeTest).Finish()
+	}
+
+	// The spans are sent once flushSpanCount spans are finished, before the exporter is flushed.
+	deadline := time.Now().Add(flushInterval / 2)
+	for spans, _ := sender.count(); spans < flushSpanCount; spans, _ = sender.count() {
+		if time.Now().After(deadline) {
+			t.Fatalf("the finished spans were not sent: %d", spans)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	e.Stop()
+	if spans, batches := sender.count(); spans != flushSpanCount+10 || batches < 2 {
+		t.Fatalf("unexpected spans sent: %d spans in %d batches", spans, batches)
+	}
+	if len(e.batcher.recorder.FinishedSpans()) != 0 {
+		t.Fatal("the sent spans are still held")
+	}
+}
@@ -0,0 +1,82 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package dd_sdk_go_testing
+
+import (
+	"time"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/constants"
+	"github.com/DataDog/dd-sdk-go-testing/internal/settings"
+	"github.com/DataDog/dd-sdk-go-testing/internal/utils"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// externalTest is a test run without the SDK, whose result is read from a report such as the
+// output of go test -json or a JUnit XML file.
+type externalTest struct {
+	suite      string
+	name       string
+	testType   string
+	start      time.Time
+	end        time.Time
+	status     string
+	errorType  string
+	errorMsg   string
+	errorStack string
+	skipReason string
+	cached     bool
+}
+
+// reportTest sends the span of a test of a suite of the session, with the tags of the spans
+// started by StartTestWithContext. The span is a child of parent unless nil.
+func (s *session) reportTest(st *testSuite, test externalTest, parent ddtrace.Span) ddtrace.Span {
+	ensureSettings()
+	testOpts := append(s.eventSpanOptions(constants.SpanTypeTest), testSpanOptions(test.suite, test.name, s.framework)...)
+	testOpts = append(testOpts, s.testSpanOptions(st)...)
+	testOpts = append(testOpts,
+		tracer.StartTime(test.start),
+		tracer.Tag(constants.TestType, test.testType),
+	)
+	if parent != nil {
+		testOpts = append(testOpts, tracer.ChildOf(parent.Context()))
+	}
+	if settings.IsNewTest(utils.TestFQN(test.suite, test.name)) {
+		testOpts = append(testOpts, tracer.Tag(constants.TestIsNew, "true"))
+	}
+	if test.cached {
+		testOpts = append(testOpts, tracer.Tag(constants.TestIsCached, "true"))
+	}
+
+	span := getExporter().StartSpan(constants.SpanTypeTest, testOpts...)
+	reported := span
+	if junitEnabled() {
+		span = wrapJUnitSpan(span, test.suite, test.name, test.start)
+	}
+	span.SetTag(constants.TestStatus, test.status)
+	span.SetTag(ext.Error, test.status == constants.TestStatusFail)
+	switch test.status {
+	case constants.TestStatusFail:
+		for key, value := range map[string]string{
+			ext.ErrorType:  test.errorType,
+			ext.ErrorMsg:   test.errorMsg,
+			ext.ErrorStack: test.errorStack,
+		} {
+			if value != "" {
+				span.SetTag(key, value)
+			}
+		}
+	case constants.TestStatusSkip:
+		if test.skipReason != "" {
+			span.SetTag(constants.TestSkipReason, test.skipReason)
+		}
+	}
+	span.Finish(tracer.FinishTime(test.end))
+
+	s.finishTest(st, test.status, test.end)
+	return reported
+}
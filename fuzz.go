@@ -0,0 +1,173 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+//go:build go1.18
+// +build go1.18
+
+package dd_sdk_go_testing
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/constants"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+var _ TB = (*testing.F)(nil)
+
+// fuzzStatsRegex matches the statistics logged by the fuzzing coordinator, the last line
+// holding the totals of the run. New interesting inputs are only counted with coverage guidance.
+var fuzzStatsRegex = regexp.MustCompile(`^fuzz: elapsed: [^,]*, execs: (\d+)(?: \([^)]*\))?(?:, new interesting: (\d+))?`)
+
+// isFuzzTest reports whether tb is a fuzz test.
+func isFuzzTest(tb TB) bool {
+	_, ok := tb.(*testing.F)
+	return ok
+}
+
+// isFuzzWorker reports whether the test binary runs as a fuzzing worker process started by the
+// coordinator, which executes the fuzz function with generated inputs and must not be traced.
+func isFuzzWorker() bool {
+	if f := flag.Lookup("test.fuzzworker"); f != nil && f.Value.String() == "true" {
+		return true
+	}
+	for _, arg := range os.Args[1:] {
+		if arg == "-test.fuzzworker" || arg == "-test.fuzzworker=true" {
+			return true
+		}
+	}
+	return false
+}
+
+// isFuzzing reports whether -test.fuzz is set, in which case the fuzz tests matching it are fuzzed
+// after the seed corpus of every test has been run.
+func isFuzzing() bool {
+	f := flag.Lookup("test.fuzz")
+	return f != nil && f.Value.String() != ""
+}
+
+// Fuzz calls f.Fuzz with ff, which must be a valid fuzz function. Each seed corpus entry runs as
+// a child span of the fuzz test span found in ctx, as returned by StartTest(f).
+//
+//	func FuzzParse(f *testing.F) {
+//		ctx, finish := ddtesting.StartTest(f)
+//		defer finish()
+//
+//		f.Add("seed")
+//		ddtesting.Fuzz(ctx, f, func(t *testing.T, input string) {
+//			Parse(input)
+//		})
+//	}
+func Fuzz(ctx context.Context, f *testing.F, ff interface{}, opts ...Option) {
+	fn := reflect.ValueOf(ff)
+	if isFuzzWorker() || fn.Kind() != reflect.Func || fn.Type().NumIn() == 0 || fn.Type().In(0) != reflect.TypeOf((*testing.T)(nil)) {
+		// Workers are not traced, and testing reports invalid fuzz functions.
+		f.Fuzz(ff)
+		return
+	}
+
+	opts = append([]Option{
+		WithOriginalFunc(ff),
+		WithSpanOptions(tracer.Tag(constants.TestType, constants.TestTypeFuzz)),
+	}, opts...)
+	wrapper := reflect.MakeFunc(fn.Type(), func(args []reflect.Value) []reflect.Value {
+		t := args[0].Interface().(*testing.T)
+		_, finish := StartTestWithContext(ctx, t, opts...)
+		defer finish()
+		return fn.Call(args)
+	})
+	f.Fuzz(wrapper.Interface())
+}
+
+// startFuzzStats starts collecting the statistics of a fuzz test when fuzzing with -fuzz, and
+// returns the function setting them on the span of the test: the number of inputs executed
+// and of new interesting inputs found, and the path of the failing input written to the corpus
+// if any. It returns nil for other tests.
+func startFuzzStats(tb TB) func(span ddtrace.Span) {
+	f, ok := tb.(*testing.F)
+	if !ok || !isFuzzing() || isFuzzWorker() {
+		return nil
+	}
+
+	stats := captureFuzzStats()
+	return func(span ddtrace.Span) {
+		if execs, newInteresting, ok := stats(); ok {
+			span.SetTag(constants.TestFuzzExecs, execs)
+			span.SetTag(constants.TestFuzzNewInteresting, newInteresting)
+		}
+		setFuzzCrasherPath(span, f)
+	}
+}
+
+// captureFuzzStats parses the statistics of the fuzzing coordinator, which writes them to the
+// os.Stderr of the time it starts. os.Stderr is replaced by a pipe copied as is to the original
+// one until the returned function restores it and returns the totals.
+func captureFuzzStats() func() (execs int, newInteresting int, ok bool) {
+	stderr := os.Stderr
+	r, w, err := os.Pipe()
+	if err != nil {
+		return func() (int, int, bool) { return 0, 0, false }
+	}
+	os.Stderr = w
+
+	var execs, newInteresting int
+	var ok bool
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// Copy the output as it's written rather than line by line, and drain the pipe even if
+		// the scanner stops.
+		s := bufio.NewScanner(io.TeeReader(r, stderr))
+		for s.Scan() {
+			if m := fuzzStatsRegex.FindStringSubmatch(s.Text()); m != nil {
+				execs, _ = strconv.Atoi(m[1])
+				newInteresting, _ = strconv.Atoi(m[2]) // 0 without coverage guidance
+				ok = true
+			}
+		}
+		io.Copy(stderr, r)
+	}()
+
+	return func() (int, int, bool) {
+		defer r.Close()
+		os.Stderr = stderr
+		w.Close()
+		<-done
+		return execs, newInteresting, ok
+	}
+}
+
+// setFuzzCrasherPath tags the span with the path of the failing input found while fuzzing. The
+// path is only available from the unexported result of testing.F, read only if it still holds an
+// error, so that other versions of the testing package are ignored.
+func setFuzzCrasherPath(span ddtrace.Span, f *testing.F) {
+	result := reflect.ValueOf(f).Elem().FieldByName("result")
+	if !result.IsValid() || result.Kind() != reflect.Struct {
+		return
+	}
+	errField := result.FieldByName("Error")
+	if !errField.IsValid() || errField.Type() != reflect.TypeOf((*error)(nil)).Elem() || !errField.CanAddr() || errField.IsNil() {
+		return
+	}
+
+	err := *(*error)(unsafe.Pointer(errField.UnsafeAddr()))
+	if crash, ok := err.(interface{ CrashPath() string }); ok {
+		span.SetTag(constants.TestFuzzCrasherPath, crash.CrashPath())
+		span.SetTag(ext.ErrorMsg, strings.TrimSpace(fmt.Sprint(err)))
+	}
+}
@@ -0,0 +1,27 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+//go:build !go1.18
+// +build !go1.18
+
+package dd_sdk_go_testing
+
+import "gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+
+// isFuzzTest reports whether tb is a fuzz test, which requires Go 1.18.
+func isFuzzTest(tb TB) bool {
+	return false
+}
+
+// isFuzzWorker reports whether the test binary runs as a fuzzing worker process, which requires
+// Go 1.18.
+func isFuzzWorker() bool {
+	return false
+}
+
+// startFuzzStats collects the statistics of a fuzz test, which requires Go 1.18.
+func startFuzzStats(tb TB) func(span ddtrace.Span) {
+	return nil
+}
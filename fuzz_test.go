@@ -0,0 +1,82 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+//go:build go1.18
+// +build go1.18
+
+package dd_sdk_go_testing
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/constants"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+)
+
+func FuzzSeedSpans(f *testing.F) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	func() {
+		ctx, finish := StartTest(f)
+		defer finish()
+
+		f.Add("a", 1)
+		f.Add("b", 2)
+		Fuzz(ctx, f, func(t *testing.T, s string, n int) {
+			if len(s) != 1 {
+				t.Fatal("unexpected input")
+			}
+		})
+	}()
+
+	spans := mt.FinishedSpans()
+	if len(spans) != 3 {
+		f.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+
+	fuzz := spans[2]
+	assertEqual(constants.TestTypeFuzz, fuzz.Tag(constants.TestType).(string))
+	assertEqual("FuzzSeedSpans", fuzz.Tag(constants.TestName).(string))
+	assertEqual(constants.TestStatusPass, fuzz.Tag(constants.TestStatus).(string))
+	for _, seed := range spans[:2] {
+		assertEqual(constants.TestTypeFuzz, seed.Tag(constants.TestType).(string))
+		assertEqual(constants.TestStatusPass, seed.Tag(constants.TestStatus).(string))
+		if seed.ParentID() != fuzz.SpanID() {
+			f.Fatal("seed span is not a child of the fuzz test span")
+		}
+	}
+	assertEqual("FuzzSeedSpans/seed#0", spans[0].Tag(constants.TestName).(string))
+}
+
+func TestCaptureFuzzStats(t *testing.T) {
+	original, err := ioutil.TempFile("", "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(original.Name())
+	defer original.Close()
+	defer func(stderr *os.File) { os.Stderr = stderr }(os.Stderr)
+	os.Stderr = original
+
+	stats := captureFuzzStats()
+	output := "fuzz: elapsed: 0s, gathering baseline coverage: 0/1 completed\n" +
+		"fuzz: elapsed: 3s, execs: 1000 (333/sec), new interesting: 2 (total: 3)\n" +
+		"fuzz: elapsed: 4s, execs: 1500 (375/sec), new interesting: 3 (total: 4)\n"
+	fmt.Fprint(os.Stderr, output)
+	execs, newInteresting, ok := stats()
+	if !ok || execs != 1500 || newInteresting != 3 {
+		t.Fatalf("unexpected statistics: %d %d %v", execs, newInteresting, ok)
+	}
+	if os.Stderr != original {
+		t.Fatal("os.Stderr was not restored")
+	}
+	if data, err := ioutil.ReadFile(original.Name()); err != nil || string(data) != output {
+		t.Fatalf("unexpected output %q: %v", data, err)
+	}
+}
@@ -8,6 +8,7 @@ package dd_sdk_go_testing
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/signal"
@@ -17,8 +18,11 @@ import (
 	"strings"
 	"syscall"
 	"testing"
+	"time"
 
 	"github.com/DataDog/dd-sdk-go-testing/internal/constants"
+	"github.com/DataDog/dd-sdk-go-testing/internal/coverage"
+	"github.com/DataDog/dd-sdk-go-testing/internal/settings"
 	"github.com/DataDog/dd-sdk-go-testing/internal/utils"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
@@ -34,41 +38,53 @@ var repoRegex = regexp.MustCompile(`(?m)\/([a-zA-Z0-9\\\-_.]*)$`)
 // FinishFunc closes a started span and attaches test status information.
 type FinishFunc func()
 
-// Run is a helper function to run a `testing.M` object and gracefully stopping the tracer afterwards
+// Run is a helper function to run a `testing.M` object and gracefully stopping the tracer afterwards.
+// It also opens the test session and module spans, which are closed with the status of the run.
+// The test events are sent with the global tracer started with opts, or with the exporter set
+// with SetExporter.
 func Run(m *testing.M, opts ...tracer.StartOption) int {
-	// Preload all CI and Git tags.
-	ensureCITags()
-
-	// Check if DD_SERVICE has been set; otherwise we default to repo name.
-	if v := os.Getenv("DD_SERVICE"); v == "" {
-		if repoUrl, ok := getFromCITags(constants.GitRepositoryURL); ok {
-			matches := repoRegex.FindStringSubmatch(repoUrl)
-			if len(matches) > 1 {
-				repoUrl = strings.TrimSuffix(matches[1], ".git")
-			}
-			opts = append(opts, tracer.WithService(repoUrl))
-		}
+	// Fuzzing workers only execute inputs on behalf of the coordinator process, which is traced.
+	if isFuzzWorker() {
+		return m.Run()
 	}
 
-	// Initialize tracer
-	tracer.Start(opts...)
-	exitFunc := func() {
-		tracer.Flush()
-		tracer.Stop()
-	}
+	exitFunc := startExporter(opts...)
 	defer exitFunc()
 
+	// Start the test session
+	s := startSession()
+
 	// Handle SIGINT and SIGTERM
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		<-signals
+		s.close(1)
+		writeJUnitReport(s.moduleName)
 		exitFunc()
 		os.Exit(1)
 	}()
 
 	// Execute test suite
-	return m.Run()
+	exitCode := m.Run()
+	s.close(exitCode)
+	writeJUnitReport(s.moduleName)
+	return exitCode
+}
+
+// getServiceName returns DD_SERVICE if set, or the repository name otherwise.
+func getServiceName() (string, bool) {
+	if v := os.Getenv("DD_SERVICE"); v != "" {
+		return v, true
+	}
+	if repoUrl, ok := getFromCITags(constants.GitRepositoryURL); ok {
+		matches := repoRegex.FindStringSubmatch(repoUrl)
+		if len(matches) > 1 {
+			repoUrl = strings.TrimSuffix(matches[1], ".git")
+		}
+		return repoUrl, true
+	}
+	return "", false
 }
 
 // TB is the minimal interface common to T and B.
@@ -81,15 +97,17 @@ type TB interface {
 var _ TB = (*testing.T)(nil)
 var _ TB = (*testing.B)(nil)
 
-// StartTest returns a new span with the given testing.TB interface and options. It uses
-// tracer.StartSpanFromContext function to start the span with automatically detected information.
+// StartTest returns a new span with the given testing.TB interface and options. The span is
+// started by the exporter selected by Run, with automatically detected information.
 func StartTest(tb TB, opts ...Option) (context.Context, FinishFunc) {
 	opts = append(opts, WithIncrementSkipFrame())
 	return StartTestWithContext(context.Background(), tb, opts...)
 }
 
-// StartTestWithContext returns a new span with the given testing.TB interface and options. It uses
-// tracer.StartSpanFromContext function to start the span with automatically detected information.
+// StartTestWithContext returns a new span with the given testing.TB interface and options. The
+// span is started by the exporter selected by Run as a child of the span of ctx, if any, with
+// automatically detected information.
+// Tests listed as skippable by the intelligent test runner are skipped before returning.
 func StartTestWithContext(ctx context.Context, tb TB, opts ...Option) (context.Context, FinishFunc) {
 	cfg := new(config)
 	defaults(cfg)
@@ -106,32 +124,93 @@ func StartTestWithContext(ctx context.Context, tb TB, opts ...Option) (context.C
 
 	suite, _ := utils.GetPackageAndName(pc)
 	name := tb.Name()
-	fqn := fmt.Sprintf("%s.%s", suite, name)
+	if cfg.testName != "" {
+		name = cfg.testName
+	}
+	fqn := utils.TestFQN(suite, name)
 
-	testOpts := []tracer.StartSpanOption{
-		tracer.ResourceName(fqn),
-		tracer.Tag(constants.TestName, name),
-		tracer.Tag(constants.TestSuite, suite),
-		tracer.Tag(constants.TestFramework, testFramework),
-		tracer.Tag(constants.Origin, constants.CIAppTestOrigin),
+	testOpts := testSpanOptions(suite, name, testFramework)
+
+	// Link the test to its source code
+	if file, startLine, endLine, ok := utils.GetSourceLocation(pc); ok {
+		workspace, _ := getFromCITags(constants.CIWorkspacePath)
+		testOpts = append(testOpts,
+			tracer.Tag(constants.TestSourceFile, utils.RelativeSourcePath(workspace, file)),
+			tracer.Tag(constants.TestSourceStartLine, startLine),
+			tracer.Tag(constants.TestSourceEndLine, endLine),
+		)
+		if owners := getCodeOwners(file); len(owners) > 0 {
+			value, _ := json.Marshal(owners)
+			testOpts = append(testOpts, tracer.Tag(constants.TestCodeOwners, string(value)))
+		}
+	}
+
+	// Group the test under the suite span of its package
+	sess := getSession()
+	var st *testSuite
+	if sess != nil {
+		st = sess.getSuite(suite, time.Now())
+		testOpts = append(testOpts, sess.testSpanOptions(st)...)
+	}
+
+	// Tag the tests missing from the known tests list
+	if settings.IsNewTest(fqn) {
+		testOpts = append(testOpts, tracer.Tag(constants.TestIsNew, "true"))
 	}
 
 	switch tb.(type) {
-	case *testing.T:
+	case *testing.T, *T:
 		testOpts = append(testOpts, tracer.Tag(constants.TestType, constants.TestTypeTest))
 	case *testing.B:
 		testOpts = append(testOpts, tracer.Tag(constants.TestType, constants.TestTypeBenchmark))
+	default:
+		if isFuzzTest(tb) {
+			testOpts = append(testOpts, tracer.Tag(constants.TestType, constants.TestTypeFuzz))
+		}
 	}
 
 	cfg.spanOpts = append(testOpts, cfg.spanOpts...)
-	span, ctx := tracer.StartSpanFromContext(ctx, constants.SpanTypeTest, cfg.spanOpts...)
+	start := time.Now()
+	span, ctx := startSpanFromContext(ctx, constants.SpanTypeTest, cfg.spanOpts...)
+	if junitEnabled() {
+		span = wrapJUnitSpan(span, suite, name, start)
+		ctx = tracer.ContextWithSpan(ctx, span)
+	}
+
+	// Skip the tests listed as skippable by the intelligent test runner
+	if skipper, ok := tb.(interface{ Skip(args ...interface{}) }); ok && settings.IsSkippableTest(fqn) {
+		span.SetTag(constants.TestStatus, constants.TestStatusSkip)
+		span.SetTag(constants.TestSkipReason, settings.ITRSkipReason)
+		span.SetTag(constants.TestSkippedByITR, "true")
+		span.Finish(cfg.finishOpts...)
+		if sess != nil {
+			sess.skipTest(st, time.Now())
+		}
+		skipper.Skip(settings.ITRSkipReason)
+	}
+
+	// Collect the statistics of the fuzz tests
+	fuzzStats := startFuzzStats(tb)
+
+	// Collect the code executed by the test
+	var cov *coverage.Collector
+	if coverage.Enabled() {
+		cov = coverage.Start()
+	}
 
 	return ctx, func() {
 		var r interface{} = nil
+		var status string
 
-		if r = recover(); r != nil {
+		r = recover()
+		if fuzzStats != nil {
+			fuzzStats(span)
+		}
+
+		if r != nil {
 			// Panic handling
-			span.SetTag(constants.TestStatus, constants.TestStatusFail)
+			status = constants.TestStatusFail
+			span.SetTag(constants.TestStatus, status)
 			span.SetTag(ext.Error, true)
 			span.SetTag(ext.ErrorMsg, fmt.Sprint(r))
 			span.SetTag(ext.ErrorStack, getStacktrace(2))
@@ -141,24 +220,66 @@ func StartTestWithContext(ctx context.Context, tb TB, opts ...Option) (context.C
 			span.SetTag(ext.Error, tb.Failed())
 
 			if tb.Failed() {
-				span.SetTag(constants.TestStatus, constants.TestStatusFail)
+				status = constants.TestStatusFail
 			} else if tb.Skipped() {
-				span.SetTag(constants.TestStatus, constants.TestStatusSkip)
+				status = constants.TestStatusSkip
 			} else {
-				span.SetTag(constants.TestStatus, constants.TestStatusPass)
+				status = constants.TestStatusPass
+			}
+			span.SetTag(constants.TestStatus, status)
+
+			if w, ok := tb.(*T); ok {
+				if status == constants.TestStatusSkip {
+					if reason := w.getSkipReason(); reason != "" {
+						span.SetTag(constants.TestSkipReason, reason)
+					}
+				} else if errType, msg, stack, ok := w.getFailureInfo(); ok && status == constants.TestStatusFail {
+					span.SetTag(ext.ErrorType, errType)
+					span.SetTag(ext.ErrorMsg, msg)
+					span.SetTag(ext.ErrorStack, stack)
+				}
+			}
+
+			if b, ok := tb.(*testing.B); ok {
+				setBenchmarkMetrics(span, b)
+			}
+
+			if cov != nil {
+				if files, err := cov.Stop(); err == nil {
+					if data, err := json.Marshal(files); err == nil {
+						span.SetTag(constants.TestCodeCoverageEnabled, "true")
+						span.SetTag(constants.TestCodeCoverageFiles, string(data))
+					}
+				}
 			}
 		}
 
 		span.Finish(cfg.finishOpts...)
+		if sess != nil {
+			sess.finishTest(st, status, time.Now())
+		}
 
 		if r != nil {
-			tracer.Flush()
-			tracer.Stop()
+			closeSession(1)
+			e := getExporter()
+			e.Flush()
+			e.Stop()
 			panic(r)
 		}
 	}
 }
 
+// testSpanOptions returns the options identifying the span of a test of a suite.
+func testSpanOptions(suite, name, framework string) []tracer.StartSpanOption {
+	return []tracer.StartSpanOption{
+		tracer.ResourceName(utils.TestFQN(suite, name)),
+		tracer.Tag(constants.TestName, name),
+		tracer.Tag(constants.TestSuite, suite),
+		tracer.Tag(constants.TestFramework, framework),
+		tracer.Tag(constants.Origin, constants.CIAppTestOrigin),
+	}
+}
+
 func getStacktrace(skip int) string {
 	pcs := make([]uintptr, 256)
 	total := runtime.Callers(skip+1, pcs)
@@ -11,6 +11,7 @@ import (
 	"testing"
 
 	"github.com/DataDog/dd-sdk-go-testing/internal/constants"
+	"github.com/DataDog/dd-sdk-go-testing/internal/settings"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
@@ -56,6 +57,9 @@ func TestStatus(t *testing.T) {
 	assertEqual(fmt.Sprintf("%s.%s", suiteName, "TestStatus/pass"), s.Tag(ext.ResourceName).(string))
 	assertEqual(framework, s.Tag(constants.TestFramework).(string))
 	assertEqual(constants.TestStatusPass, s.Tag(constants.TestStatus).(string))
+	assertEqual("init_test.go", s.Tag(constants.TestSourceFile).(string))
+	assertEqual("28", fmt.Sprint(s.Tag(constants.TestSourceStartLine)))
+	assertEqual("34", fmt.Sprint(s.Tag(constants.TestSourceEndLine)))
 	commonEqualCheck(s)
 	commonNotEmptyCheck(s)
 	fmt.Println(s)
@@ -100,6 +104,41 @@ func TestPanic(t *testing.T) {
 	assertNotEmpty(s.Tag(ext.ErrorStack).(string))
 }
 
+func TestITRSkip(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	settings.SetSkippableTests(settings.SkippableTests{
+		"github.com/DataDog/dd-sdk-go-testing.TestITRSkip/skippable": true,
+	})
+	defer settings.SetSkippableTests(nil)
+
+	withTestSession(func(s *session) {
+		t.Run("skippable", func(t *testing.T) {
+			_, finish := StartTest(t)
+			defer finish()
+			t.Fatal("skippable test executed")
+		})
+		s.close(0)
+	})
+
+	spans := mt.FinishedSpans()
+	if len(spans) != 4 {
+		t.FailNow()
+	}
+
+	s := spans[0]
+	assertEqual(constants.TestStatusSkip, s.Tag(constants.TestStatus).(string))
+	assertEqual(settings.ITRSkipReason, s.Tag(constants.TestSkipReason).(string))
+	assertEqual("true", s.Tag(constants.TestSkippedByITR).(string))
+
+	s = spans[3]
+	assertEqual(constants.SpanTypeTestSession, s.Tag(ext.SpanType).(string))
+	assertEqual("true", s.Tag(constants.TestITRSkippingEnabled).(string))
+	assertEqual("true", s.Tag(constants.ITRTestsSkipped).(string))
+	assertEqual("1", fmt.Sprint(s.Tag(constants.TestITRSkippingCount)))
+}
+
 func commonEqualCheck(s mocktracer.Span) {
 	assertEqual(constants.SpanTypeTest, s.Tag(ext.SpanType).(string))
 	assertEqual(constants.SpanTypeTest, s.Tag(constants.SpanKind).(string))
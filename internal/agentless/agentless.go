@@ -0,0 +1,187 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+// Package agentless sends the test events straight to the CI Visibility intake, without a
+// Datadog agent. It replaces the HTTP transport of the tracer: the traces the tracer sends to
+// the agent are converted to citestcycle events and posted to the intake instead, or written to
+// local files in offline mode, to be uploaded to an agent or the intake later.
+package agentless
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/utils"
+)
+
+const (
+	intakePath = "/api/v2/citestcycle"
+
+	// defaultMaxPayloadSize is the maximum size of the events of a single request, before
+	// compression.
+	defaultMaxPayloadSize = 5 * 1024 * 1024
+
+	defaultRetries = 3
+	defaultBackoff = 100 * time.Millisecond
+)
+
+// Enabled reports whether agentless mode is enabled with DD_CIVISIBILITY_AGENTLESS_ENABLED and
+// an API key is available in DD_API_KEY.
+func Enabled() bool {
+	return utils.BoolEnv("DD_CIVISIBILITY_AGENTLESS_ENABLED", false) && os.Getenv("DD_API_KEY") != ""
+}
+
+// URL returns the intake endpoint from DD_CIVISIBILITY_AGENTLESS_URL, or from DD_SITE otherwise.
+func URL() string {
+	if url := os.Getenv("DD_CIVISIBILITY_AGENTLESS_URL"); url != "" {
+		return strings.TrimSuffix(url, "/") + intakePath
+	}
+	site := os.Getenv("DD_SITE")
+	if site == "" {
+		site = "datadoghq.com"
+	}
+	return fmt.Sprintf("https://citestcycle-intake.%s%s", site, intakePath)
+}
+
+// NewHTTPClient returns an HTTP client for tracer.WithHTTPClient that sends the traces to the
+// intake configured by the environment.
+func NewHTTPClient() *http.Client {
+	return &http.Client{Transport: NewTransport(URL(), os.Getenv("DD_API_KEY"))}
+}
+
+// Transport is an http.RoundTripper that posts the traces sent by the tracer to the intake
+// as citestcycle events, and answers the other requests of the tracer on behalf of the agent.
+type Transport struct {
+	url            string
+	apiKey         string
+	client         *http.Client
+	maxPayloadSize int
+	retries        int
+	backoff        time.Duration
+}
+
+// NewTransport returns a transport posting the events to the intake url with apiKey.
+func NewTransport(url, apiKey string) *Transport {
+	return &Transport{
+		url:            url,
+		apiKey:         apiKey,
+		client:         &http.Client{Timeout: 30 * time.Second},
+		maxPayloadSize: defaultMaxPayloadSize,
+		retries:        defaultRetries,
+		backoff:        defaultBackoff,
+	}
+}
+
+// RoundTrip implements http.RoundTripper. The events are sent synchronously, so flushing or
+// stopping the tracer waits for them to reach the intake.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		defer req.Body.Close()
+	}
+
+	if req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/traces") {
+		spans, err := decodeTraces(req.Body)
+		if err != nil {
+			return nil, fmt.Errorf("agentless: unable to decode traces: %v", err)
+		}
+		if err := t.Send(spans); err != nil {
+			return nil, err
+		}
+	}
+
+	return agentResponse(req), nil
+}
+
+// agentResponse returns the response of the agent to a request of the tracer.
+func agentResponse(req *http.Request) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader("{}")),
+		Request:    req,
+	}
+}
+
+// Send posts the spans to the intake, in as many requests as needed to keep each one under
+// the maximum payload size.
+func (t *Transport) Send(spans []*Span) error {
+	if len(spans) == 0 {
+		return nil
+	}
+
+	events := make([][]byte, 0, len(spans))
+	for _, s := range spans {
+		events = append(events, appendEvent(nil, s))
+	}
+
+	md := metadata(spans)
+	for _, chunk := range chunkEvents(events, t.maxPayloadSize) {
+		if err := t.post(encodePayload(md, chunk)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// post sends a gzipped payload, retrying on network errors, throttling and server errors.
+func (t *Transport) post(payload []byte) error {
+	var body bytes.Buffer
+	gz := gzip.NewWriter(&body)
+	if _, err := gz.Write(payload); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	var err error
+	backoff := t.backoff
+	for attempt := 0; attempt < t.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		var retry bool
+		if retry, err = t.postOnce(body.Bytes()); err == nil || !retry {
+			return err
+		}
+	}
+	return err
+}
+
+// postOnce sends the gzipped payload once, and reports whether a failed request can be retried.
+func (t *Transport) postOnce(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/msgpack")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("dd-api-key", t.apiKey)
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("agentless: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	msg, _ := ioutil.ReadAll(resp.Body)
+	err = fmt.Errorf("agentless: unexpected status code %d: %s", resp.StatusCode, string(msg))
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
@@ -0,0 +1,184 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package agentless
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// appendSpan encodes a span the way the tracer does in v0.4 traces payloads.
+func appendSpan(b []byte, spanID uint64, spanType string, meta map[string]string) []byte {
+	b = msgp.AppendMapHeader(b, 12)
+	b = msgp.AppendString(b, "name")
+	b = msgp.AppendString(b, spanType)
+	b = msgp.AppendString(b, "service")
+	b = msgp.AppendString(b, "app")
+	b = msgp.AppendString(b, "resource")
+	b = msgp.AppendString(b, "resource")
+	b = msgp.AppendString(b, "type")
+	b = msgp.AppendString(b, spanType)
+	b = msgp.AppendString(b, "start")
+	b = msgp.AppendInt64(b, 1)
+	b = msgp.AppendString(b, "duration")
+	b = msgp.AppendInt64(b, 2)
+	b = msgp.AppendString(b, "meta")
+	b = msgp.AppendMapStrStr(b, meta)
+	b = msgp.AppendString(b, "metrics")
+	b = msgp.AppendMapHeader(b, 1)
+	b = msgp.AppendString(b, "_sampling_priority_v1")
+	b = msgp.AppendFloat64(b, 1)
+	b = msgp.AppendString(b, "span_id")
+	b = msgp.AppendUint64(b, spanID)
+	b = msgp.AppendString(b, "trace_id")
+	b = msgp.AppendUint64(b, spanID)
+	b = msgp.AppendString(b, "parent_id")
+	b = msgp.AppendUint64(b, 0)
+	b = msgp.AppendString(b, "error")
+	b = msgp.AppendInt32(b, 0)
+	return b
+}
+
+func tracesPayload() []byte {
+	b := msgp.AppendArrayHeader(nil, 2)
+	b = msgp.AppendArrayHeader(b, 1)
+	b = appendSpan(b, 1, "test_session_end", map[string]string{"runtime-id": "abc"})
+	b = msgp.AppendArrayHeader(b, 1)
+	b = appendSpan(b, 2, "test", map[string]string{"test_session_id": "1", "test.name": "TestA"})
+	return b
+}
+
+// intake records the payloads received by a test server, failing the first failures requests.
+type intake struct {
+	mutex    sync.Mutex
+	failures int
+	payloads []map[string]interface{}
+}
+
+func (i *intake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	if i.failures > 0 {
+		i.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if r.URL.Path != intakePath || r.Header.Get("dd-api-key") != "api-key" || r.Header.Get("Content-Encoding") != "gzip" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	gz, err := gzip.NewReader(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	payload, err := msgp.NewReader(gz).ReadIntf()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	i.payloads = append(i.payloads, payload.(map[string]interface{}))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (i *intake) events() []map[string]interface{} {
+	var events []map[string]interface{}
+	for _, p := range i.payloads {
+		for _, e := range p["events"].([]interface{}) {
+			events = append(events, e.(map[string]interface{}))
+		}
+	}
+	return events
+}
+
+func newTestTransport(i *intake) (*Transport, func()) {
+	server := httptest.NewServer(i)
+	transport := NewTransport(server.URL+intakePath, "api-key")
+	transport.backoff = time.Millisecond
+	return transport, server.Close
+}
+
+func sendTraces(t *testing.T, transport http.RoundTripper) {
+	client := &http.Client{Transport: transport}
+	resp, err := client.Post("http://localhost:8126/v0.4/traces", "application/msgpack", bytes.NewReader(tracesPayload()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status code %d", resp.StatusCode)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	i := &intake{failures: 1}
+	transport, closeServer := newTestTransport(i)
+	defer closeServer()
+
+	sendTraces(t, transport)
+
+	if len(i.payloads) != 1 {
+		t.Fatalf("expected 1 payload, got %d", len(i.payloads))
+	}
+	metadata := i.payloads[0]["metadata"].(map[string]interface{})["*"].(map[string]interface{})
+	if metadata["language"] != "go" || metadata["runtime-id"] != "abc" {
+		t.Fatalf("unexpected metadata: %v", metadata)
+	}
+
+	events := i.events()
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+
+	session := events[0]["content"].(map[string]interface{})
+	if events[0]["type"] != "test_session_end" || fmt.Sprint(session["test_session_id"]) != "1" {
+		t.Fatalf("unexpected session event: %v", events[0])
+	}
+	test := events[1]["content"].(map[string]interface{})
+	meta := test["meta"].(map[string]interface{})
+	if events[1]["type"] != "test" || fmt.Sprint(test["test_session_id"]) != "1" || fmt.Sprint(test["span_id"]) != "2" {
+		t.Fatalf("unexpected test event: %v", events[1])
+	}
+	if _, ok := meta["test_session_id"]; ok || meta["test.name"] != "TestA" {
+		t.Fatalf("unexpected test meta: %v", meta)
+	}
+}
+
+func TestTransportChunks(t *testing.T) {
+	i := &intake{}
+	transport, closeServer := newTestTransport(i)
+	defer closeServer()
+
+	transport.maxPayloadSize = 1
+	sendTraces(t, transport)
+
+	if len(i.payloads) != 2 || len(i.events()) != 2 {
+		t.Fatalf("expected 2 payloads of 1 event, got %d payloads", len(i.payloads))
+	}
+}
+
+func TestTransportRetries(t *testing.T) {
+	i := &intake{failures: defaultRetries}
+	transport, closeServer := newTestTransport(i)
+	defer closeServer()
+
+	client := &http.Client{Transport: transport}
+	if _, err := client.Post("http://localhost:8126/v0.4/traces", "application/msgpack", bytes.NewReader(tracesPayload())); err == nil {
+		t.Fatal("expected an error after exhausting the retries")
+	}
+	if len(i.payloads) != 0 || i.failures != 0 {
+		t.Fatalf("unexpected intake state: %d payloads, %d failures left", len(i.payloads), i.failures)
+	}
+}
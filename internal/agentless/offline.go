@@ -0,0 +1,139 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package agentless
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/utils"
+)
+
+const (
+	// OfflineFileExt is the extension of the files written in offline mode.
+	OfflineFileExt = ".ndjson"
+
+	// defaultMaxFileSize is the size after which the events are written to a new file.
+	defaultMaxFileSize = 10 * 1024 * 1024
+)
+
+// OfflineDir returns the directory the test events are written to in offline mode, set with
+// DD_CIVISIBILITY_OFFLINE_DIR, or an empty string if offline mode is disabled.
+func OfflineDir() string {
+	return os.Getenv("DD_CIVISIBILITY_OFFLINE_DIR")
+}
+
+// MaxFileSize returns the size after which the events are written to a new file, set with
+// DD_CIVISIBILITY_OFFLINE_MAX_FILE_SIZE.
+func MaxFileSize() int64 {
+	return int64(utils.IntEnv("DD_CIVISIBILITY_OFFLINE_MAX_FILE_SIZE", defaultMaxFileSize))
+}
+
+// NewOfflineHTTPClient returns an HTTP client for tracer.WithHTTPClient that writes the traces
+// to the files of the offline directory.
+func NewOfflineHTTPClient() *http.Client {
+	return &http.Client{Transport: NewFileTransport(OfflineDir(), MaxFileSize())}
+}
+
+// FileTransport is an http.RoundTripper that appends the spans of the traces sent by the tracer
+// to files as JSON lines, and answers the requests of the tracer on behalf of the agent. Each
+// process writes its own files, and starts a new one when the current file would exceed the
+// maximum size.
+type FileTransport struct {
+	dir     string
+	maxSize int64
+	prefix  string
+
+	mutex sync.Mutex
+	file  *os.File
+	size  int64
+	count int
+}
+
+// NewFileTransport returns a transport writing the spans to files of dir of at most maxSize
+// bytes, unless the spans of a single payload of the tracer are larger.
+func NewFileTransport(dir string, maxSize int64) *FileTransport {
+	return &FileTransport{
+		dir:     dir,
+		maxSize: maxSize,
+		prefix:  fmt.Sprintf("ddtest-%s-%d", time.Now().UTC().Format("20060102T150405"), os.Getpid()),
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *FileTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		defer req.Body.Close()
+	}
+
+	if req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/traces") {
+		spans, err := decodeTraces(req.Body)
+		if err != nil {
+			return nil, fmt.Errorf("agentless: unable to decode traces: %v", err)
+		}
+		if err := t.Send(spans); err != nil {
+			return nil, err
+		}
+	}
+	return agentResponse(req), nil
+}
+
+// Send appends the spans to the current file, one per line. The spans are written at once, so
+// that an interrupted process leaves whole lines behind.
+func (t *FileTransport) Send(spans []*Span) error {
+	if len(spans) == 0 {
+		return nil
+	}
+
+	var b []byte
+	for _, s := range spans {
+		line, err := json.Marshal(s)
+		if err != nil {
+			return fmt.Errorf("agentless: unable to encode span: %v", err)
+		}
+		b = append(append(b, line...), '\n')
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.file == nil || (t.size > 0 && t.size+int64(len(b)) > t.maxSize) {
+		if err := t.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := t.file.Write(b)
+	t.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("agentless: %v", err)
+	}
+	return nil
+}
+
+// rotate closes the current file and creates the next one.
+func (t *FileTransport) rotate() error {
+	if t.file != nil {
+		t.file.Close()
+		t.file = nil
+	}
+	if err := os.MkdirAll(t.dir, 0755); err != nil {
+		return fmt.Errorf("agentless: %v", err)
+	}
+
+	t.count++
+	name := filepath.Join(t.dir, fmt.Sprintf("%s-%d%s", t.prefix, t.count, OfflineFileExt))
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("agentless: %v", err)
+	}
+	t.file, t.size = f, 0
+	return nil
+}
@@ -0,0 +1,115 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package agentless
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func offlineFiles(t *testing.T, dir string) []string {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+OfflineFileExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestFileTransport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "offline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	transport := NewFileTransport(filepath.Join(dir, "events"), 1)
+	sendTraces(t, transport)
+	sendTraces(t, transport)
+
+	// Every payload is larger than the maximum size, and is written to a file of its own.
+	files := offlineFiles(t, filepath.Join(dir, "events"))
+	if len(files) != 2 {
+		t.Fatalf("expected 2 files, got %v", files)
+	}
+	spans, err := readOfflineFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spans) != 2 || spans[1].Type != "test" || spans[1].Meta["test.name"] != "TestA" || spans[1].SpanID != 2 {
+		t.Fatalf("unexpected spans: %v", spans)
+	}
+
+	// An interrupted write leaves an incomplete line behind.
+	f, err := os.OpenFile(files[1], os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"name":"test","type":"te`)
+	f.Close()
+	if spans, err = readOfflineFile(files[1]); err != nil || len(spans) != 2 {
+		t.Fatalf("unexpected spans: %v, %v", spans, err)
+	}
+}
+
+func TestUploadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "offline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sendTraces(t, NewFileTransport(dir, defaultMaxFileSize))
+	files := offlineFiles(t, dir)
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file, got %v", files)
+	}
+
+	t.Run("intake", func(t *testing.T) {
+		i := &intake{}
+		transport, closeServer := newTestTransport(i)
+		defer closeServer()
+
+		if err := transport.UploadFile(files[0]); err != nil {
+			t.Fatal(err)
+		}
+		events := i.events()
+		if len(events) != 2 || events[0]["type"] != "test_session_end" || events[1]["type"] != "test" {
+			t.Fatalf("unexpected events: %v", events)
+		}
+	})
+
+	t.Run("agent", func(t *testing.T) {
+		var mutex sync.Mutex
+		var spans []*Span
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			if r.URL.Path != agentTracesPath || r.Header.Get("X-Datadog-Trace-Count") != "2" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			decoded, err := decodeTraces(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			spans = append(spans, decoded...)
+		}))
+		defer server.Close()
+
+		if err := NewAgentClient(server.URL).UploadFile(files[0]); err != nil {
+			t.Fatal(err)
+		}
+		if len(spans) != 2 || spans[0].Meta["runtime-id"] != "abc" || spans[1].Metrics["_sampling_priority_v1"] != 1 {
+			t.Fatalf("unexpected spans: %v", spans)
+		}
+	})
+}
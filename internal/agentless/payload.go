@@ -0,0 +1,154 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package agentless
+
+import (
+	"strconv"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/constants"
+	"github.com/tinylib/msgp/msgp"
+)
+
+const (
+	// payloadVersion is the version of the citestcycle payload format.
+	payloadVersion = 1
+
+	// spanEventType is the event type of the spans which are not test events.
+	spanEventType = "span"
+)
+
+// eventType returns the citestcycle event type of a span: test, test_suite_end,
+// test_module_end, test_session_end or span.
+func eventType(s *Span) string {
+	switch s.Type {
+	case constants.SpanTypeTest, constants.SpanTypeTestSuite, constants.SpanTypeTestModule, constants.SpanTypeTestSession:
+		return s.Type
+	default:
+		return spanEventType
+	}
+}
+
+// appendEvent encodes a span as a citestcycle event and appends it to b.
+func appendEvent(b []byte, s *Span) []byte {
+	typ := eventType(s)
+	meta := make(map[string]string, len(s.Meta))
+	for k, v := range s.Meta {
+		meta[k] = v
+	}
+	metrics := make(map[string]interface{}, len(s.Metrics))
+	for k, v := range s.Metrics {
+		metrics[k] = v
+	}
+
+	content := map[string]interface{}{
+		"name":     s.Name,
+		"service":  s.Service,
+		"resource": s.Resource,
+		"type":     s.Type,
+		"start":    s.Start,
+		"duration": s.Duration,
+		"error":    s.Error,
+		"meta":     meta,
+		"metrics":  metrics,
+	}
+
+	// Test events are correlated to their session, module and suite through numeric ids,
+	// while the spans of the suites, modules and sessions carry their own id.
+	switch typ {
+	case constants.SpanTypeTestSession:
+		content["test_session_id"] = s.SpanID
+	case constants.SpanTypeTestModule:
+		content["test_module_id"] = s.SpanID
+	case constants.SpanTypeTestSuite:
+		content["test_suite_id"] = s.SpanID
+	default:
+		content["trace_id"] = s.TraceID
+		content["span_id"] = s.SpanID
+		content["parent_id"] = s.ParentID
+	}
+	for _, key := range []string{constants.TestSessionID, constants.TestModuleID, constants.TestSuiteID} {
+		if value, ok := meta[key]; ok {
+			if id, err := strconv.ParseUint(value, 10, 64); err == nil {
+				content[key] = id
+				delete(meta, key)
+			}
+		}
+	}
+
+	version := 1
+	if typ == constants.SpanTypeTest {
+		version = 2
+	}
+
+	b = msgp.AppendMapHeader(b, 3)
+	b = msgp.AppendString(b, "type")
+	b = msgp.AppendString(b, typ)
+	b = msgp.AppendString(b, "version")
+	b = msgp.AppendInt(b, version)
+	b = msgp.AppendString(b, "content")
+	// content only holds types supported by AppendIntf.
+	b, _ = msgp.AppendIntf(b, content)
+	return b
+}
+
+// encodePayload encodes already encoded events in a citestcycle payload:
+//
+//	{"version": 1, "metadata": {"*": metadata}, "events": [...]}
+func encodePayload(metadata map[string]string, events [][]byte) []byte {
+	size := 64
+	for _, event := range events {
+		size += len(event)
+	}
+
+	b := make([]byte, 0, size)
+	b = msgp.AppendMapHeader(b, 3)
+	b = msgp.AppendString(b, "version")
+	b = msgp.AppendInt(b, payloadVersion)
+	b = msgp.AppendString(b, "metadata")
+	b = msgp.AppendMapHeader(b, 1)
+	b = msgp.AppendString(b, "*")
+	b = msgp.AppendMapStrStr(b, metadata)
+	b = msgp.AppendString(b, "events")
+	b = msgp.AppendArrayHeader(b, uint32(len(events)))
+	for _, event := range events {
+		b = append(b, event...)
+	}
+	return b
+}
+
+// chunkEvents groups the encoded events in chunks of at most maxSize bytes. An event larger
+// than maxSize is sent in its own chunk.
+func chunkEvents(events [][]byte, maxSize int) [][][]byte {
+	var chunks [][][]byte
+	var chunk [][]byte
+	size := 0
+	for _, event := range events {
+		if len(chunk) > 0 && size+len(event) > maxSize {
+			chunks = append(chunks, chunk)
+			chunk, size = nil, 0
+		}
+		chunk = append(chunk, event)
+		size += len(event)
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// metadata returns the metadata shared by all the events of a payload.
+func metadata(spans []*Span) map[string]string {
+	md := map[string]string{"language": "go"}
+	for _, s := range spans {
+		if id, ok := s.Meta["runtime-id"]; ok {
+			md["runtime-id"] = id
+		}
+		if env, ok := s.Meta["env"]; ok {
+			md["env"] = env
+		}
+	}
+	return md
+}
@@ -0,0 +1,143 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package agentless
+
+import (
+	"io"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// Span is a span decoded from a v0.4 traces payload of the tracer. Its JSON encoding is a line of
+// the files written in offline mode.
+type Span struct {
+	Name     string             `json:"name"`
+	Service  string             `json:"service"`
+	Resource string             `json:"resource"`
+	Type     string             `json:"type"`
+	Start    int64              `json:"start"`
+	Duration int64              `json:"duration"`
+	Meta     map[string]string  `json:"meta"`
+	Metrics  map[string]float64 `json:"metrics"`
+	SpanID   uint64             `json:"span_id"`
+	TraceID  uint64             `json:"trace_id"`
+	ParentID uint64             `json:"parent_id"`
+	Error    int32              `json:"error"`
+}
+
+// decodeTraces decodes a v0.4 traces payload, an array of traces which are arrays of spans,
+// and returns the spans of all the traces.
+func decodeTraces(r io.Reader) ([]*Span, error) {
+	dc := msgp.NewReader(r)
+	traces, err := dc.ReadArrayHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	var spans []*Span
+	for i := uint32(0); i < traces; i++ {
+		count, err := dc.ReadArrayHeader()
+		if err != nil {
+			return nil, err
+		}
+		for j := uint32(0); j < count; j++ {
+			s, err := decodeSpan(dc)
+			if err != nil {
+				return nil, err
+			}
+			spans = append(spans, s)
+		}
+	}
+	return spans, nil
+}
+
+func decodeSpan(dc *msgp.Reader) (*Span, error) {
+	fields, err := dc.ReadMapHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Span{Meta: map[string]string{}, Metrics: map[string]float64{}}
+	for i := uint32(0); i < fields; i++ {
+		field, err := dc.ReadString()
+		if err != nil {
+			return nil, err
+		}
+		if dc.IsNil() {
+			if err := dc.ReadNil(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		switch field {
+		case "name":
+			s.Name, err = dc.ReadString()
+		case "service":
+			s.Service, err = dc.ReadString()
+		case "resource":
+			s.Resource, err = dc.ReadString()
+		case "type":
+			s.Type, err = dc.ReadString()
+		case "start":
+			s.Start, err = dc.ReadInt64()
+		case "duration":
+			s.Duration, err = dc.ReadInt64()
+		case "meta":
+			err = decodeMeta(dc, s.Meta)
+		case "metrics":
+			err = decodeMetrics(dc, s.Metrics)
+		case "span_id":
+			s.SpanID, err = dc.ReadUint64()
+		case "trace_id":
+			s.TraceID, err = dc.ReadUint64()
+		case "parent_id":
+			s.ParentID, err = dc.ReadUint64()
+		case "error":
+			s.Error, err = dc.ReadInt32()
+		default:
+			err = dc.Skip()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func decodeMeta(dc *msgp.Reader, meta map[string]string) error {
+	size, err := dc.ReadMapHeader()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < size; i++ {
+		key, err := dc.ReadString()
+		if err != nil {
+			return err
+		}
+		if meta[key], err = dc.ReadString(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeMetrics(dc *msgp.Reader, metrics map[string]float64) error {
+	size, err := dc.ReadMapHeader()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < size; i++ {
+		key, err := dc.ReadString()
+		if err != nil {
+			return err
+		}
+		if metrics[key], err = dc.ReadFloat64(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package agentless
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+const (
+	agentTracesPath = "/v0.4/traces"
+
+	// defaultAgentPayloadSize is the maximum size of the traces of a single request to the agent.
+	defaultAgentPayloadSize = 5 * 1024 * 1024
+)
+
+// UploadFile posts the spans of a file written in offline mode to the intake.
+func (t *Transport) UploadFile(path string) error {
+	spans, err := readOfflineFile(path)
+	if err != nil {
+		return err
+	}
+	return t.Send(spans)
+}
+
+// AgentURL returns the URL of the agent from DD_TRACE_AGENT_URL, or from DD_AGENT_HOST and
+// DD_TRACE_AGENT_PORT otherwise, as the tracer does.
+func AgentURL() string {
+	if url := os.Getenv("DD_TRACE_AGENT_URL"); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	host, port := os.Getenv("DD_AGENT_HOST"), os.Getenv("DD_TRACE_AGENT_PORT")
+	if host == "" {
+		host = "localhost"
+	}
+	if port == "" {
+		port = "8126"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+// AgentClient posts spans to an agent as the tracer does, such as the spans of the files written
+// in offline mode.
+type AgentClient struct {
+	url            string
+	client         *http.Client
+	maxPayloadSize int
+}
+
+// NewAgentClient returns a client posting the spans to the agent at url.
+func NewAgentClient(url string) *AgentClient {
+	return &AgentClient{
+		url:            strings.TrimSuffix(url, "/") + agentTracesPath,
+		client:         &http.Client{Timeout: 30 * time.Second},
+		maxPayloadSize: defaultAgentPayloadSize,
+	}
+}
+
+// UploadFile posts the spans of a file written in offline mode to the agent.
+func (u *AgentClient) UploadFile(path string) error {
+	spans, err := readOfflineFile(path)
+	if err != nil {
+		return err
+	}
+	return u.Send(spans)
+}
+
+// Send posts the spans to the agent, in as many requests as needed to keep each one under the
+// maximum payload size.
+func (u *AgentClient) Send(spans []*Span) error {
+	// The spans of a trace are sent together, in the order of their first span.
+	var order []uint64
+	traces := map[uint64][]*Span{}
+	for _, s := range spans {
+		if _, ok := traces[s.TraceID]; !ok {
+			order = append(order, s.TraceID)
+		}
+		traces[s.TraceID] = append(traces[s.TraceID], s)
+	}
+	encoded := make([][]byte, 0, len(order))
+	for _, id := range order {
+		b := msgp.AppendArrayHeader(nil, uint32(len(traces[id])))
+		for _, s := range traces[id] {
+			b = appendAgentSpan(b, s)
+		}
+		encoded = append(encoded, b)
+	}
+
+	for _, chunk := range chunkEvents(encoded, u.maxPayloadSize) {
+		if err := u.post(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// post sends encoded traces to the agent as a v0.4 traces payload.
+func (u *AgentClient) post(traces [][]byte) error {
+	b := msgp.AppendArrayHeader(nil, uint32(len(traces)))
+	for _, trace := range traces {
+		b = append(b, trace...)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, u.url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/msgpack")
+	req.Header.Set("Datadog-Meta-Lang", "go")
+	req.Header.Set("X-Datadog-Trace-Count", strconv.Itoa(len(traces)))
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("agentless: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	msg, _ := ioutil.ReadAll(resp.Body)
+	return fmt.Errorf("agentless: unexpected status code %d: %s", resp.StatusCode, string(msg))
+}
+
+// appendAgentSpan encodes a span as the tracer does in v0.4 traces payloads and appends it to b.
+func appendAgentSpan(b []byte, s *Span) []byte {
+	b = msgp.AppendMapHeader(b, 12)
+	b = msgp.AppendString(b, "name")
+	b = msgp.AppendString(b, s.Name)
+	b = msgp.AppendString(b, "service")
+	b = msgp.AppendString(b, s.Service)
+	b = msgp.AppendString(b, "resource")
+	b = msgp.AppendString(b, s.Resource)
+	b = msgp.AppendString(b, "type")
+	b = msgp.AppendString(b, s.Type)
+	b = msgp.AppendString(b, "start")
+	b = msgp.AppendInt64(b, s.Start)
+	b = msgp.AppendString(b, "duration")
+	b = msgp.AppendInt64(b, s.Duration)
+	b = msgp.AppendString(b, "meta")
+	b = msgp.AppendMapStrStr(b, s.Meta)
+	b = msgp.AppendString(b, "metrics")
+	b = msgp.AppendMapHeader(b, uint32(len(s.Metrics)))
+	for k, v := range s.Metrics {
+		b = msgp.AppendString(b, k)
+		b = msgp.AppendFloat64(b, v)
+	}
+	b = msgp.AppendString(b, "span_id")
+	b = msgp.AppendUint64(b, s.SpanID)
+	b = msgp.AppendString(b, "trace_id")
+	b = msgp.AppendUint64(b, s.TraceID)
+	b = msgp.AppendString(b, "parent_id")
+	b = msgp.AppendUint64(b, s.ParentID)
+	b = msgp.AppendString(b, "error")
+	b = msgp.AppendInt32(b, s.Error)
+	return b
+}
+
+// readOfflineFile returns the spans of a file written in offline mode. The last line of a file
+// left behind by an interrupted process may be incomplete, and is ignored.
+func readOfflineFile(path string) ([]*Span, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var spans []*Span
+	r := bufio.NewReader(f)
+	for n := 1; ; n++ {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			return spans, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		s := &Span{}
+		if err := json.Unmarshal(line, s); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		spans = append(spans, s)
+	}
+}
@@ -33,9 +33,22 @@ const (
 	// GitCommitSHA indicates git commit SHA1 hash related to the build.
 	GitCommitSHA = "git.commit.sha"
 
+	// GitHeadCommit indicates the head commit of the pull request, which may differ from the commit
+	// built when the provider builds the merge of the pull request.
+	GitHeadCommit = "git.commit.head.sha"
+
+	// GitPrBaseBranch indicates the branch the pull request targets.
+	GitPrBaseBranch = "git.pull_request.base_branch"
+
+	// GitPrBaseCommit indicates the commit of the branch the pull request targets.
+	GitPrBaseCommit = "git.pull_request.base_branch_sha"
+
 	// GitRepositoryURL indicates git repository URL related to the build.
 	GitRepositoryURL = "git.repository_url"
 
 	// GitTag indicates the current git tag.
 	GitTag = "git.tag"
+
+	// PrNumber indicates the number of the pull request.
+	PrNumber = "pr.number"
 )
@@ -8,4 +8,13 @@ package constants
 const (
 	// SpanTypeTest marks a span as a test execution.
 	SpanTypeTest = "test"
+
+	// SpanTypeTestSuite marks a span as a test suite (package) execution.
+	SpanTypeTestSuite = "test_suite_end"
+
+	// SpanTypeTestModule marks a span as a test module (test binary) execution.
+	SpanTypeTestModule = "test_module_end"
+
+	// SpanTypeTestSession marks a span as a test session execution.
+	SpanTypeTestSession = "test_session_end"
 )
@@ -12,18 +12,66 @@ const (
 	// TestSuite indicates the test suite name.
 	TestSuite = "test.suite"
 
+	// TestModule indicates the test module name.
+	TestModule = "test.module"
+
+	// TestCommand indicates the command used to run the test session.
+	TestCommand = "test.command"
+
+	// TestSessionID indicates the ID of the test session a span belongs to.
+	TestSessionID = "test_session_id"
+
+	// TestModuleID indicates the ID of the test module a span belongs to.
+	TestModuleID = "test_module_id"
+
+	// TestSuiteID indicates the ID of the test suite a span belongs to.
+	TestSuiteID = "test_suite_id"
+
 	// TestFramework indicates the test framework name.
 	TestFramework = "test.framework"
 
 	// TestStatus indicates the test execution status.
 	TestStatus = "test.status"
 
-	// TestType indicates the type of the test (test, benchmark).
+	// TestType indicates the type of the test (test, benchmark, fuzz, example).
 	TestType = "test.type"
 
 	// TestSkipReason indicates the skip reason of the test.
 	TestSkipReason = "test.skip_reason"
 
+	// TestIsNew indicates the test is missing from the known tests list.
+	TestIsNew = "test.is_new"
+
+	// TestEarlyFlakeDetectionEnabled indicates early flake detection is enabled in the session.
+	TestEarlyFlakeDetectionEnabled = "test.early_flake.enabled"
+
+	// TestIsRetry indicates the test execution is a retry of a failed test.
+	TestIsRetry = "test.is_retry"
+
+	// TestRetryNumber indicates the number of the retry of a failed test, starting at 1.
+	TestRetryNumber = "test.retry_number"
+
+	// TestSkippedByITR indicates the test was skipped by the intelligent test runner.
+	TestSkippedByITR = "test.skipped_by_itr"
+
+	// TestITRSkippingEnabled indicates test skipping is enabled in the session.
+	TestITRSkippingEnabled = "test.itr.tests_skipping.enabled"
+
+	// TestITRSkippingCount indicates how many tests were skipped by the intelligent test runner.
+	TestITRSkippingCount = "test.itr.tests_skipping.count"
+
+	// ITRTestsSkipped indicates whether any test was skipped by the intelligent test runner.
+	ITRTestsSkipped = "_dd.ci.itr.tests_skipped"
+
+	// TestCodeCoverageEnabled indicates per-test code coverage is enabled.
+	TestCodeCoverageEnabled = "test.code_coverage.enabled"
+
+	// TestCodeCoverageLinesPercentage indicates the statement coverage of the test session.
+	TestCodeCoverageLinesPercentage = "test.code_coverage.lines_pct"
+
+	// TestCodeCoverageFiles indicates the blocks of each source file executed by the test, as JSON.
+	TestCodeCoverageFiles = "test.code_coverage.files"
+
 	// TestSourceFile indicates the source file where the test is located.
 	TestSourceFile = "test.source.file"
 
@@ -32,6 +80,37 @@ const (
 
 	// TestSourceEndLine indicates the line of the source file where the test ends.
 	TestSourceEndLine = "test.source.end"
+
+	// TestCodeOwners indicates the owners of the test source file, as a JSON array.
+	TestCodeOwners = "test.codeowners"
+
+	// TestFuzzExecs indicates the number of inputs executed while fuzzing.
+	TestFuzzExecs = "test.fuzz.execs"
+
+	// TestFuzzNewInteresting indicates the number of new interesting inputs found while fuzzing.
+	TestFuzzNewInteresting = "test.fuzz.new_interesting"
+
+	// TestFuzzCrasherPath indicates the path of the file where the failing input found while
+	// fuzzing was written.
+	TestFuzzCrasherPath = "test.fuzz.crasher_path"
+
+	// TestIsCached indicates that the test result was replayed from the go test cache.
+	TestIsCached = "test.is_cached"
+
+	// BenchmarkRuns indicates the number of iterations of a benchmark run (b.N).
+	BenchmarkRuns = "benchmark.runs"
+
+	// BenchmarkDurationMean indicates the mean duration of a benchmark iteration in nanoseconds (ns/op).
+	BenchmarkDurationMean = "benchmark.duration.mean"
+
+	// BenchmarkAllocatedBytes indicates the bytes allocated by a benchmark iteration (B/op).
+	BenchmarkAllocatedBytes = "benchmark.allocated_bytes"
+
+	// BenchmarkAllocs indicates the allocations of a benchmark iteration (allocs/op).
+	BenchmarkAllocs = "benchmark.allocs"
+
+	// BenchmarkMetricPrefix prefixes the unit of the metrics reported with b.ReportMetric.
+	BenchmarkMetricPrefix = "benchmark.metrics."
 )
 
 // Define valid test status types.
@@ -53,4 +132,10 @@ const (
 
 	// TestTypeBenchmark defines test type as benchmark.
 	TestTypeBenchmark = "benchmark"
+
+	// TestTypeFuzz defines test type as fuzz test.
+	TestTypeFuzz = "fuzz"
+
+	// TestTypeExample defines test type as example.
+	TestTypeExample = "example"
 )
@@ -0,0 +1,202 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/utils"
+)
+
+var (
+	// writeProfile writes the text coverage profile of the counters of the test binary, set by
+	// Register.
+	writeProfile func(dir, profile string) error
+
+	// snapshotMu serializes the snapshots of the counters and guards last.
+	snapshotMu sync.Mutex
+
+	// last is the latest snapshot of the counters, which the next collectors start from.
+	last map[block]int64
+
+	// unavailableOnce logs once why the coverage requested can't be collected.
+	unavailableOnce sync.Once
+)
+
+// Enabled reports whether coverage is enabled through DD_CIVISIBILITY_CODE_COVERAGE_ENABLED and
+// the test binary was built with -cover.
+func Enabled() bool {
+	if !utils.BoolEnv("DD_CIVISIBILITY_CODE_COVERAGE_ENABLED", false) {
+		return false
+	}
+	if testing.CoverMode() == "" {
+		logUnavailable("code coverage is disabled: the test binary was not built with -cover")
+		return false
+	}
+	return true
+}
+
+// logUnavailable logs the reason why the coverage can't be collected, once per test binary.
+func logUnavailable(reason string) {
+	unavailableOnce.Do(func() {
+		log.Printf("dd-sdk-go-testing: %s", reason)
+	})
+}
+
+// Total returns the statement coverage of the test binary as a percentage.
+func Total() float64 {
+	return testing.Coverage() * 100
+}
+
+// File holds the blocks of a source file executed by a test. Each segment is the start line and
+// column and the end line and column of a block, as in the coverage profiles.
+type File struct {
+	Filename string   `json:"filename"`
+	Segments [][4]int `json:"segments"`
+}
+
+// block is the position of a block of statements in a coverage profile.
+type block struct {
+	file                                 string
+	startLine, startCol, endLine, endCol int
+}
+
+// Collector collects the blocks executed between its start and stop, by comparing snapshots of
+// the counters of the test binary. The blocks executed since the previous collector stopped are
+// given to the next one, and parallel tests the blocks executed by all the tests running at the
+// same time.
+type Collector struct {
+	start map[block]int64
+}
+
+// Start starts from the latest snapshot of the counters, taken when the previous collector
+// stopped, or from a new snapshot for the first one. It returns nil when the counters can't be
+// read: the test main must call Register, which the test mains rewritten by ddtest-toolexec do,
+// and the coverage mode must be count or atomic, as the set mode doesn't tell whether a block
+// was executed again.
+func Start() *Collector {
+	if writeProfile == nil {
+		logUnavailable("per-test code coverage is disabled: the test main was not instrumented by ddtest-toolexec with Go 1.20 or later")
+		return nil
+	}
+	if testing.CoverMode() == "set" {
+		logUnavailable("per-test code coverage is disabled: it requires -covermode=count or atomic")
+		return nil
+	}
+
+	snapshotMu.Lock()
+	defer snapshotMu.Unlock()
+	if last == nil {
+		counts, err := snapshot()
+		if err != nil {
+			return nil
+		}
+		last = counts
+	}
+	return &Collector{start: last}
+}
+
+// Stop returns the files with the blocks executed since Start, sorted by name, and keeps the
+// snapshot of the counters for the next collectors.
+func (c *Collector) Stop() ([]File, error) {
+	snapshotMu.Lock()
+	defer snapshotMu.Unlock()
+	counts, err := snapshot()
+	if err != nil {
+		return nil, err
+	}
+	last = counts
+	return diff(c.start, counts), nil
+}
+
+// snapshot returns the counters of the blocks of the test binary. The caller must hold
+// snapshotMu.
+func snapshot() (map[block]int64, error) {
+	dir, err := ioutil.TempDir("", "ddcoverage")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	profile := filepath.Join(dir, "coverage.out")
+	if err := writeProfile(dir, profile); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(profile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseProfile(f)
+}
+
+// parseProfile parses a text coverage profile, made of a mode line followed by lines with the
+// position of a block, its number of statements and its counter:
+//
+//	mode: count
+//	example.com/pkg/file.go:4.2,6.11 2 1
+func parseProfile(r io.Reader) (map[block]int64, error) {
+	counts := map[block]int64{}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+		fields := strings.Fields(line[strings.LastIndexByte(line, ':')+1:])
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid coverage profile line: %s", line)
+		}
+		b := block{file: line[:strings.LastIndexByte(line, ':')]}
+		if _, err := fmt.Sscanf(fields[0], "%d.%d,%d.%d", &b.startLine, &b.startCol, &b.endLine, &b.endCol); err != nil {
+			return nil, fmt.Errorf("invalid coverage profile line: %s", line)
+		}
+		count, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid coverage profile line: %s", line)
+		}
+		counts[b] += count
+	}
+	return counts, s.Err()
+}
+
+// diff returns the files with the blocks whose counter increased from start to end.
+func diff(start, end map[block]int64) []File {
+	byFile := map[string][]block{}
+	for b, count := range end {
+		if count > start[b] {
+			byFile[b.file] = append(byFile[b.file], b)
+		}
+	}
+
+	files := make([]File, 0, len(byFile))
+	for name, blocks := range byFile {
+		sort.Slice(blocks, func(i, j int) bool {
+			if blocks[i].startLine != blocks[j].startLine {
+				return blocks[i].startLine < blocks[j].startLine
+			}
+			return blocks[i].startCol < blocks[j].startCol
+		})
+		f := File{Filename: name, Segments: make([][4]int, len(blocks))}
+		for i, b := range blocks {
+			f.Segments[i] = [4]int{b.startLine, b.startCol, b.endLine, b.endCol}
+		}
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Filename < files[j].Filename })
+	return files
+}
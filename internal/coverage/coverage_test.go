@@ -0,0 +1,120 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package coverage
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const startProfile = `mode: count
+example.com/pkg/a.go:4.2,4.11 1 1
+example.com/pkg/a.go:5.3,6.1 1 0
+example.com/pkg/a.go:7.2,7.10 1 0
+example.com/pkg/b.go:10.16,10.26 1 2
+`
+
+const endProfile = `mode: count
+example.com/pkg/a.go:4.2,4.11 1 2
+example.com/pkg/a.go:5.3,6.1 1 1
+example.com/pkg/a.go:7.2,7.10 1 0
+example.com/pkg/b.go:10.16,10.26 1 2
+`
+
+func TestDiff(t *testing.T) {
+	start, err := parseProfile(strings.NewReader(startProfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	end, err := parseProfile(strings.NewReader(endProfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFiles(t, `[{"filename":"example.com/pkg/a.go","segments":[[4,2,4,11],[5,3,6,1]]}]`, diff(start, end))
+	assertFiles(t, `[]`, diff(end, end))
+
+	if _, err := parseProfile(strings.NewReader("mode: count\nexample.com/pkg/a.go:4.2 1\n")); err == nil {
+		t.Fatal("an invalid profile should fail")
+	}
+}
+
+func TestCollector(t *testing.T) {
+	defer func(fn func(dir, profile string) error) { writeProfile, last = fn, nil }(writeProfile)
+
+	profiles := []string{startProfile, endProfile, endProfile}
+	writeProfile = func(dir, profile string) error {
+		if len(profiles) == 0 {
+			t.Fatal("unexpected snapshot")
+		}
+		p := profiles[0]
+		profiles = profiles[1:]
+		return ioutil.WriteFile(profile, []byte(p), 0644)
+	}
+	if testing.CoverMode() == "set" {
+		if Start() != nil {
+			t.Fatal("the collector needs the counters of the count or atomic modes")
+		}
+		return
+	}
+
+	c := Start()
+	if c == nil {
+		t.Fatal("the collector was not started")
+	}
+	files, err := c.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFiles(t, `[{"filename":"example.com/pkg/a.go","segments":[[4,2,4,11],[5,3,6,1]]}]`, files)
+
+	// The next collector starts from the snapshot of the previous one.
+	c = Start()
+	files, err = c.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFiles(t, `[]`, files)
+	if len(profiles) != 0 {
+		t.Fatalf("%d snapshots were not taken", len(profiles))
+	}
+}
+
+func TestCollectorUnavailable(t *testing.T) {
+	defer func(fn func(dir, profile string) error) { writeProfile = fn }(writeProfile)
+	defer log.SetOutput(os.Stderr)
+	defer func(flags int) { log.SetFlags(flags) }(log.Flags())
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	unavailableOnce = sync.Once{}
+	writeProfile = nil
+	for i := 0; i < 2; i++ {
+		if Start() != nil {
+			t.Fatal("the collector can't read the counters without Register")
+		}
+	}
+	if buf.String() != "dd-sdk-go-testing: per-test code coverage is disabled: the test main was not instrumented by ddtest-toolexec with Go 1.20 or later\n" {
+		t.Fatalf("unexpected log: %q", buf.String())
+	}
+}
+
+func assertFiles(t *testing.T, expected string, files []File) {
+	t.Helper()
+	data, err := json.Marshal(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != expected {
+		t.Fatalf("unexpected files %s, expected %s", data, expected)
+	}
+}
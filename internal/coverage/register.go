@@ -0,0 +1,31 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+//go:build go1.20
+// +build go1.20
+
+package coverage
+
+import (
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// Register sets the function of the test main writing the coverage profile at the end of the
+// tests, testdeps.CoverProcessTestDirFunc, which Go 1.20 and later set when building with -cover.
+// It writes the counters to a directory and the text profile to a file while the tests run.
+func Register(processTestDir interface{}) {
+	switch fn := processTestDir.(type) {
+	case func(dir, cfile, cm, cpkg string, w io.Writer, selpkgs []string) error:
+		writeProfile = func(dir, profile string) error {
+			return fn(dir, profile, testing.CoverMode(), "", ioutil.Discard, nil)
+		}
+	case func(dir, cfile, cm, cpkg string, w io.Writer) error:
+		writeProfile = func(dir, profile string) error {
+			return fn(dir, profile, testing.CoverMode(), "", ioutil.Discard)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package rewrite
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+)
+
+const (
+	// AutoinstrumentPath is the import path of the package running the rewritten subtests.
+	AutoinstrumentPath = "github.com/DataDog/dd-sdk-go-testing/autoinstrument"
+
+	// autoinstrumentName is the name the autoinstrument package is imported with by the
+	// rewritten files, unlikely to collide with a name declared by the test file.
+	autoinstrumentName = "__dd_autoinstrument"
+)
+
+// edit replaces the bytes of a source file between two offsets.
+type edit struct {
+	start, end int
+	text       string
+}
+
+// Subtests rewrites the t.Run calls of a test file, where t is a *testing.T parameter, into
+// autoinstrument.Run calls, which trace the subtests as child spans of the span of t. It returns
+// the rewritten source and whether it changed.
+//
+// The edits are made in place so that the rewritten calls and the rest of the file keep their
+// line numbers, and a line directive keeps the name of the original file in the positions
+// reported by the compiler and the runtime.
+func Subtests(filename string, src []byte) ([]byte, bool, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, false, err
+	}
+
+	testingName, ok := importName(file, "testing")
+	if !ok {
+		return src, false, nil
+	}
+
+	var edits []edit
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) != 2 || call.Ellipsis.IsValid() {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || sel.Sel.Name != "Run" {
+			return true
+		}
+		t, ok := sel.X.(*ast.Ident)
+		if !ok || !isTestingT(t, testingName) {
+			return true
+		}
+
+		edits = append(edits, edit{
+			start: fset.Position(call.Fun.Pos()).Offset,
+			end:   fset.Position(call.Lparen).Offset + 1,
+			text:  fmt.Sprintf("%s.Run(%s, ", autoinstrumentName, t.Name),
+		})
+		return true
+	})
+	if len(edits) == 0 {
+		return src, false, nil
+	}
+
+	out := new(bytes.Buffer)
+	fmt.Fprintf(out, "//line %s:1\n", filename)
+	out.Write(apply(src, append(edits, importAutoinstrument(fset, file))))
+	return out.Bytes(), true, nil
+}
+
+// importAutoinstrument returns the edit importing autoinstrument on the line of the package
+// clause, so that the following lines keep their numbers.
+func importAutoinstrument(fset *token.FileSet, file *ast.File) edit {
+	offset := fset.Position(file.Name.End()).Offset
+	return edit{
+		start: offset,
+		end:   offset,
+		text:  fmt.Sprintf("; import %s %s", autoinstrumentName, strconv.Quote(AutoinstrumentPath)),
+	}
+}
+
+// apply returns src with the edits applied, which must not overlap.
+func apply(src []byte, edits []edit) []byte {
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+
+	out := new(bytes.Buffer)
+	last := 0
+	for _, e := range edits {
+		out.Write(src[last:e.start])
+		out.WriteString(e.text)
+		last = e.end
+	}
+	out.Write(src[last:])
+	return out.Bytes()
+}
+
+// importName returns the name a file imports a package with, which can't be a dot or blank
+// import to be referred to.
+func importName(file *ast.File, path string) (string, bool) {
+	for _, spec := range file.Imports {
+		if p, err := strconv.Unquote(spec.Path.Value); err != nil || p != path {
+			continue
+		}
+		if spec.Name == nil {
+			return path, true
+		}
+		if spec.Name.Name == "." || spec.Name.Name == "_" {
+			return "", false
+		}
+		return spec.Name.Name, true
+	}
+	return "", false
+}
+
+// isTestingT reports whether the identifier refers to a parameter or variable declared with the
+// *testing.T type, testing being the name the file imports the testing package with.
+func isTestingT(ident *ast.Ident, testingName string) bool {
+	if ident.Obj == nil || ident.Obj.Kind != ast.Var {
+		return false
+	}
+
+	var typ ast.Expr
+	switch decl := ident.Obj.Decl.(type) {
+	case *ast.Field:
+		typ = decl.Type
+	case *ast.ValueSpec:
+		typ = decl.Type
+	}
+	star, ok := typ.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := star.X.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "T" {
+		return false
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	return ok && pkg.Name == testingName && pkg.Obj == nil
+}
@@ -0,0 +1,85 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package rewrite
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+const subtestsSrc = `package example // comment
+
+import (
+	gotesting "testing"
+)
+
+type runner struct{}
+
+func (runner) Run(name string, f func(*gotesting.T)) bool { return true }
+
+func TestTable(t *gotesting.T) {
+	for _, tc := range []string{"a", "b"} {
+		t.Run(tc, func(t *gotesting.T) {
+			t.Run("nested", nested)
+		})
+	}
+	var r runner
+	r.Run("runner", nil)
+}
+
+func nested(tt *gotesting.T) {
+	tt.Run("inner", func(*gotesting.T) {})
+}
+`
+
+func TestSubtests(t *testing.T) {
+	out, ok, err := Subtests("/src/example_test.go", []byte(subtestsSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("the file was not rewritten")
+	}
+
+	src := string(out)
+	if _, err := parser.ParseFile(token.NewFileSet(), "", out, 0); err != nil {
+		t.Fatalf("invalid rewritten source: %v\n%s", err, src)
+	}
+
+	expected := []string{
+		"//line /src/example_test.go:1",
+		`package example; import __dd_autoinstrument "github.com/DataDog/dd-sdk-go-testing/autoinstrument" // comment`,
+		`		__dd_autoinstrument.Run(t, tc, func(t *gotesting.T) {`,
+		`			__dd_autoinstrument.Run(t, "nested", nested)`,
+		`	r.Run("runner", nil)`,
+		`	__dd_autoinstrument.Run(tt, "inner", func(*gotesting.T) {})`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(src, line+"\n") {
+			t.Fatalf("missing line %q in:\n%s", line, src)
+		}
+	}
+	if outLines, srcLines := strings.Count(src, "\n"), strings.Count(subtestsSrc, "\n"); outLines != srcLines+1 {
+		t.Fatalf("expected %d lines, got %d", srcLines+1, outLines)
+	}
+}
+
+func TestSubtestsUnchanged(t *testing.T) {
+	for _, src := range []string{
+		"package example\n\nfunc run(t interface{ Run(string, func()) }) { t.Run(\"a\", nil) }\n",
+		"package example\n\nimport . \"testing\"\n\nfunc TestDot(t *T) { t.Run(\"a\", nil) }\n",
+	} {
+		out, ok, err := Subtests("example_test.go", []byte(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok || string(out) != src {
+			t.Fatalf("unexpected rewrite:\n%s", out)
+		}
+	}
+}
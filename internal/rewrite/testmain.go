@@ -0,0 +1,127 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package rewrite
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+)
+
+// instrumentationName is the name of the autoinstrument.Instrumentation variable declared by the
+// rewritten main function.
+const instrumentationName = "__dd_instrumentation"
+
+// coverProcessTestDirFunc is the variable set by the test mains of the binaries built with -cover
+// to the function writing the coverage profile.
+const coverProcessTestDirFunc = "testdeps.CoverProcessTestDirFunc"
+
+// testedPackageRegex matches the import path of the tested package set by the test main.
+var testedPackageRegex = regexp.MustCompile(`testdeps\.ImportPath = "([^"]*)"`)
+
+// testMainArrays are the variables of the test main holding the tests given to
+// testing.MainStart, and the methods of autoinstrument.Instrumentation instrumenting them.
+var testMainArrays = []struct{ name, method string }{
+	{"tests", "Tests"},
+	{"benchmarks", "Benchmarks"},
+	{"fuzzTargets", "FuzzTargets"},
+	{"examples", "Examples"},
+}
+
+// TestedPackage returns the import path of the package tested by the test main generated by go
+// test, if known.
+func TestedPackage(src []byte) string {
+	if m := testedPackageRegex.FindSubmatch(src); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
+
+// TestMain rewrites the _testmain.go file generated by go test so that the tests, benchmarks,
+// fuzz tests and examples are instrumented with autoinstrument.Instrumentation before being
+// given to testing.MainStart, and run in a test session. It returns the rewritten source and
+// whether it changed.
+//
+// The tests of the packages defining a TestMain function are not instrumented: their TestMain
+// calls m.Run, and can call autoinstrument.RunM instead. The test mains of the binaries built
+// with -cover give the function writing the coverage profile to autoinstrument.RegisterCoverage
+// in any case, so that the blocks executed by each test can be reported.
+func TestMain(filename string, src []byte) ([]byte, bool, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, 0)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var main *ast.FuncDecl
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == "main" && fn.Body != nil {
+			main = fn
+		}
+	}
+	if main == nil {
+		return src, false, nil
+	}
+
+	// Find the m.Run() call of os.Exit(m.Run()), absent when TestMain calls it.
+	var run *ast.CallExpr
+	hasTestMain := false
+	ast.Inspect(main.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		switch sel.Sel.Name {
+		case "TestMain":
+			hasTestMain = true
+		case "Run":
+			if _, ok := sel.X.(*ast.Ident); ok && len(call.Args) == 0 {
+				run = call
+			}
+		}
+		return true
+	})
+	instrumentTests := !hasTestMain && run != nil
+	cover := bytes.Contains(src, []byte(coverProcessTestDirFunc))
+	if !instrumentTests && !cover {
+		return src, false, nil
+	}
+
+	instrument := new(bytes.Buffer)
+	if cover {
+		fmt.Fprintf(instrument, "\n\t%s.RegisterCoverage(%s)", autoinstrumentName, coverProcessTestDirFunc)
+	}
+	if instrumentTests {
+		fmt.Fprintf(instrument, "\n\t%s := %s.NewInstrumentation()", instrumentationName, autoinstrumentName)
+		for _, array := range testMainArrays {
+			if obj := file.Scope.Lookup(array.name); obj != nil && obj.Kind == ast.Var {
+				fmt.Fprintf(instrument, "\n\t%[1]s = %[2]s.%[3]s(%[1]s)", array.name, instrumentationName, array.method)
+			}
+		}
+	}
+
+	lbrace := fset.Position(main.Body.Lbrace).Offset + 1
+	edits := []edit{
+		importAutoinstrument(fset, file),
+		{start: lbrace, end: lbrace, text: instrument.String()},
+	}
+	if instrumentTests {
+		m := run.Fun.(*ast.SelectorExpr).X.(*ast.Ident)
+		edits = append(edits, edit{
+			start: fset.Position(run.Pos()).Offset,
+			end:   fset.Position(run.End()).Offset,
+			text:  fmt.Sprintf("%s.Run(%s)", instrumentationName, m.Name),
+		})
+	}
+	return apply(src, edits), true, nil
+}
@@ -0,0 +1,117 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package rewrite
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+const testMainSrc = `// Code generated by 'go test'. DO NOT EDIT.
+
+package main
+
+import (
+	"os"
+
+	"testing"
+	"testing/internal/testdeps"
+
+	_test "example.com/e2e"
+)
+
+var tests = []testing.InternalTest{
+	{"TestTable", _test.TestTable},
+}
+
+var benchmarks = []testing.InternalBenchmark{
+}
+
+var examples = []testing.InternalExample{
+}
+
+func init() {
+	testdeps.ImportPath = "example.com/e2e"
+}
+
+func main() {
+	m := testing.MainStart(testdeps.TestDeps{}, tests, benchmarks, examples)
+
+	os.Exit(m.Run())
+}
+`
+
+func TestTestMain(t *testing.T) {
+	out, ok, err := TestMain("_testmain.go", []byte(testMainSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("the test main was not rewritten")
+	}
+
+	src := string(out)
+	if _, err := parser.ParseFile(token.NewFileSet(), "", out, 0); err != nil {
+		t.Fatalf("invalid rewritten source: %v\n%s", err, src)
+	}
+	expected := `func main() {
+	__dd_instrumentation := __dd_autoinstrument.NewInstrumentation()
+	tests = __dd_instrumentation.Tests(tests)
+	benchmarks = __dd_instrumentation.Benchmarks(benchmarks)
+	examples = __dd_instrumentation.Examples(examples)
+	m := testing.MainStart(testdeps.TestDeps{}, tests, benchmarks, examples)
+
+	os.Exit(__dd_instrumentation.Run(m))
+}
+`
+	if !strings.HasSuffix(src, expected) {
+		t.Fatalf("unexpected main function:\n%s", src)
+	}
+	assertEqual(t, "example.com/e2e", TestedPackage(out))
+}
+
+func TestTestMainWithTestMain(t *testing.T) {
+	src := strings.Replace(testMainSrc, "\tos.Exit(m.Run())", "\t_test.TestMain(m)\n\tos.Exit(0)", 1)
+	out, ok, err := TestMain("_testmain.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok || string(out) != src {
+		t.Fatalf("unexpected rewrite:\n%s", out)
+	}
+}
+
+func TestTestMainWithCoverage(t *testing.T) {
+	src := strings.Replace(testMainSrc, "\ttestdeps.ImportPath", "\ttestdeps.CoverProcessTestDirFunc = cfile.ProcessCoverTestDir\n\ttestdeps.ImportPath", 1)
+	src = strings.Replace(src, "\tos.Exit(m.Run())", "\t_test.TestMain(m)\n\tos.Exit(0)", 1)
+	out, ok, err := TestMain("_testmain.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("the test main was not rewritten")
+	}
+	expected := `func main() {
+	__dd_autoinstrument.RegisterCoverage(testdeps.CoverProcessTestDirFunc)
+	m := testing.MainStart(testdeps.TestDeps{}, tests, benchmarks, examples)
+
+	_test.TestMain(m)
+	os.Exit(0)
+}
+`
+	if !strings.HasSuffix(string(out), expected) {
+		t.Fatalf("unexpected main function:\n%s", out)
+	}
+}
+
+func assertEqual(t *testing.T, expected, actual string) {
+	t.Helper()
+	if expected != actual {
+		t.Fatalf("expected %q, actual %q", expected, actual)
+	}
+}
@@ -0,0 +1,115 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package settings
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	knownTestsPath     = "/api/v2/ci/libraries/tests"
+	skippableTestsPath = "/api/v2/ci/tests/skippable"
+)
+
+// Request identifies the service and repository whose settings are requested.
+type Request struct {
+	Service        string            `json:"service"`
+	Env            string            `json:"env"`
+	RepositoryURL  string            `json:"repository_url"`
+	Sha            string            `json:"sha,omitempty"`
+	Configurations map[string]string `json:"configurations"`
+}
+
+// Client requests the test settings of a service from the Datadog API.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewClient returns a client for the API at baseURL authenticated with apiKey.
+func NewClient(baseURL, apiKey string) *Client {
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// APIURL returns the base URL of the Datadog API from DD_CIVISIBILITY_API_URL,
+// or from DD_SITE otherwise.
+func APIURL() string {
+	if url := os.Getenv("DD_CIVISIBILITY_API_URL"); url != "" {
+		return url
+	}
+	site := os.Getenv("DD_SITE")
+	if site == "" {
+		site = "datadoghq.com"
+	}
+	return fmt.Sprintf("https://api.%s", site)
+}
+
+// GetKnownTests returns the tests already known by the backend for the requested service.
+func (c *Client) GetKnownTests(req Request) (KnownTests, error) {
+	var response knownTestsResponse
+	if err := c.post(knownTestsPath, "ci_app_libraries_tests_request", req, &response); err != nil {
+		return nil, err
+	}
+	return response.Data.Attributes.Tests, nil
+}
+
+// GetSkippableTests returns the tests that can be skipped for the requested commit.
+func (c *Client) GetSkippableTests(req Request) (SkippableTests, error) {
+	var response skippableTestsResponse
+	attributes := skippableTestsRequest{Request: req, TestLevel: "test"}
+	if err := c.post(skippableTestsPath, "test_params", attributes, &response); err != nil {
+		return nil, err
+	}
+	return response.skippableTests(), nil
+}
+
+// post sends the request attributes in a JSON:API envelope and decodes the response into out.
+func (c *Client) post(path, requestType string, attributes interface{}, out interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"data": map[string]interface{}{
+			"id":         "1",
+			"type":       requestType,
+			"attributes": attributes,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("dd-api-key", c.apiKey)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected status code %d: %s", path, resp.StatusCode, string(data))
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,72 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package settings
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"time"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/utils"
+)
+
+// KnownTests contains the test names known by the backend, grouped by module and suite.
+type KnownTests map[string]map[string][]string
+
+type knownTestsResponse struct {
+	Data struct {
+		Attributes struct {
+			Tests KnownTests `json:"tests"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+// LoadKnownTestsFile reads a known tests list from a JSON file with the same format as the
+// response of the API:
+//
+//	{"data": {"attributes": {"tests": {"module": {"suite": ["TestName"]}}}}}
+func LoadKnownTestsFile(path string) (KnownTests, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var response knownTestsResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, err
+	}
+	return response.Data.Attributes.Tests, nil
+}
+
+// FullyQualifiedNames returns the set of known tests keyed by their fully qualified name.
+func (k KnownTests) FullyQualifiedNames() map[string]bool {
+	names := map[string]bool{}
+	for _, suites := range k {
+		for suite, tests := range suites {
+			for _, test := range tests {
+				names[utils.TestFQN(suite, test)] = true
+			}
+		}
+	}
+	return names
+}
+
+// EarlyFlakeDetectionExecutions returns how many times a new test should be executed given
+// the duration of its first execution. Slow tests are executed fewer times.
+func EarlyFlakeDetectionExecutions(duration time.Duration) int {
+	switch {
+	case duration < 5*time.Second:
+		return 10
+	case duration < 10*time.Second:
+		return 5
+	case duration < 30*time.Second:
+		return 3
+	case duration < 5*time.Minute:
+		return 2
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,125 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package settings
+
+import (
+	"log"
+	"os"
+	"sync"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/utils"
+)
+
+var (
+	// knownTests contains the fully qualified names of the known tests, nil when early flake
+	// detection is disabled.
+	knownTests      map[string]bool
+	knownTestsMutex sync.RWMutex
+
+	// skippableTests contains the fully qualified names of the tests that can be skipped, nil
+	// when test skipping is disabled.
+	skippableTests      SkippableTests
+	skippableTestsMutex sync.RWMutex
+)
+
+// ITRSkipReason is the skip reason of the tests skipped by the intelligent test runner.
+const ITRSkipReason = "Skipped by Datadog Intelligent Test Runner"
+
+// Load loads the settings enabled through environment variables:
+//
+//   - DD_CIVISIBILITY_EARLY_FLAKE_DETECTION_ENABLED enables early flake detection. The known
+//     tests are read from DD_CIVISIBILITY_KNOWN_TESTS_FILE if set, or requested from the API
+//     using DD_API_KEY otherwise.
+//   - DD_CIVISIBILITY_ITR_ENABLED enables test skipping. The skippable tests are read from
+//     DD_CIVISIBILITY_SKIPPABLE_TESTS_FILE if set, or requested from the API using DD_API_KEY
+//     otherwise.
+func Load(req Request) {
+	if utils.BoolEnv("DD_CIVISIBILITY_EARLY_FLAKE_DETECTION_ENABLED", false) {
+		tests, err := loadKnownTests(req)
+		if err != nil {
+			log.Printf("dd-sdk-go-testing: early flake detection disabled, unable to load known tests: %v", err)
+		} else {
+			SetKnownTests(tests)
+		}
+	}
+	if utils.BoolEnv("DD_CIVISIBILITY_ITR_ENABLED", false) {
+		tests, err := loadSkippableTests(req)
+		if err != nil {
+			log.Printf("dd-sdk-go-testing: test skipping disabled, unable to load skippable tests: %v", err)
+		} else {
+			SetSkippableTests(tests)
+		}
+	}
+}
+
+func loadKnownTests(req Request) (KnownTests, error) {
+	if path := os.Getenv("DD_CIVISIBILITY_KNOWN_TESTS_FILE"); path != "" {
+		return LoadKnownTestsFile(path)
+	}
+	if apiKey := os.Getenv("DD_API_KEY"); apiKey != "" {
+		return NewClient(APIURL(), apiKey).GetKnownTests(req)
+	}
+	return nil, nil
+}
+
+func loadSkippableTests(req Request) (SkippableTests, error) {
+	if path := os.Getenv("DD_CIVISIBILITY_SKIPPABLE_TESTS_FILE"); path != "" {
+		return LoadSkippableTestsFile(path)
+	}
+	if apiKey := os.Getenv("DD_API_KEY"); apiKey != "" {
+		return NewClient(APIURL(), apiKey).GetSkippableTests(req)
+	}
+	return nil, nil
+}
+
+// SetKnownTests replaces the known tests list. An empty list disables early flake detection,
+// otherwise every test of a new repository would be considered new.
+func SetKnownTests(tests KnownTests) {
+	names := tests.FullyQualifiedNames()
+	knownTestsMutex.Lock()
+	defer knownTestsMutex.Unlock()
+	if len(names) == 0 {
+		knownTests = nil
+	} else {
+		knownTests = names
+	}
+}
+
+// EarlyFlakeDetectionEnabled reports whether a known tests list has been loaded.
+func EarlyFlakeDetectionEnabled() bool {
+	knownTestsMutex.RLock()
+	defer knownTestsMutex.RUnlock()
+	return knownTests != nil
+}
+
+// IsNewTest reports whether the test with the given suite.name is missing from the known
+// tests list. It always returns false when early flake detection is disabled.
+func IsNewTest(fqn string) bool {
+	knownTestsMutex.RLock()
+	defer knownTestsMutex.RUnlock()
+	return knownTests != nil && !knownTests[fqn]
+}
+
+// SetSkippableTests replaces the skippable tests list. A nil list disables test skipping.
+func SetSkippableTests(tests SkippableTests) {
+	skippableTestsMutex.Lock()
+	defer skippableTestsMutex.Unlock()
+	skippableTests = tests
+}
+
+// TestSkippingEnabled reports whether a skippable tests list has been loaded.
+func TestSkippingEnabled() bool {
+	skippableTestsMutex.RLock()
+	defer skippableTestsMutex.RUnlock()
+	return skippableTests != nil
+}
+
+// IsSkippableTest reports whether the test with the given suite.name can be skipped.
+func IsSkippableTest(fqn string) bool {
+	skippableTestsMutex.RLock()
+	defer skippableTestsMutex.RUnlock()
+	return skippableTests[fqn]
+}
@@ -0,0 +1,165 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package settings
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/utils"
+)
+
+const knownTestsJson = `{"data":{"id":"1","type":"ci_app_libraries_tests","attributes":{"tests":{
+	"github.com/DataDog/app":{"github.com/DataDog/app":["TestA","TestB"]},
+	"github.com/DataDog/app/sub":{"github.com/DataDog/app/sub":["TestC"]}}}}}`
+
+func TestGetKnownTests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != knownTestsPath || r.Header.Get("dd-api-key") != "api-key" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var body struct {
+			Data struct {
+				Type       string  `json:"type"`
+				Attributes Request `json:"attributes"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Data.Attributes.Service != "app" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(knownTestsJson))
+	}))
+	defer server.Close()
+
+	tests, err := NewClient(server.URL, "api-key").GetKnownTests(Request{Service: "app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := tests.FullyQualifiedNames()
+	if len(names) != 3 || !names["github.com/DataDog/app.TestA"] || !names["github.com/DataDog/app/sub.TestC"] {
+		t.Fatalf("unexpected known tests: %v", names)
+	}
+
+	if _, err := NewClient(server.URL, "wrong-key").GetKnownTests(Request{Service: "app"}); err == nil {
+		t.Fatal("expected an error for an unexpected status code")
+	}
+}
+
+func TestLoadKnownTestsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "known-tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "known_tests.json")
+	if err := ioutil.WriteFile(path, []byte(knownTestsJson), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests, err := LoadKnownTestsFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetKnownTests(tests)
+	defer SetKnownTests(nil)
+
+	if !EarlyFlakeDetectionEnabled() {
+		t.Fatal("early flake detection should be enabled")
+	}
+	if IsNewTest("github.com/DataDog/app.TestA") {
+		t.Fatal("TestA should be a known test")
+	}
+	if !IsNewTest("github.com/DataDog/app.TestD") {
+		t.Fatal("TestD should be a new test")
+	}
+}
+
+const skippableTestsJson = `{"meta":{"correlation_id":"1234"},"data":[
+	{"id":"1","type":"test","attributes":{"suite":"github.com/DataDog/app","name":"TestA"}},
+	{"id":"2","type":"test","attributes":{"suite":"github.com/DataDog/app/sub","name":"TestC"}}]}`
+
+func TestGetSkippableTests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Data struct {
+				Type       string `json:"type"`
+				Attributes struct {
+					Sha       string `json:"sha"`
+					TestLevel string `json:"test_level"`
+				} `json:"attributes"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || r.URL.Path != skippableTestsPath ||
+			body.Data.Type != "test_params" || body.Data.Attributes.Sha != "abcd" || body.Data.Attributes.TestLevel != "test" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(skippableTestsJson))
+	}))
+	defer server.Close()
+
+	tests, err := NewClient(server.URL, "api-key").GetSkippableTests(Request{Service: "app", Sha: "abcd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetSkippableTests(tests)
+	defer SetSkippableTests(nil)
+
+	if !TestSkippingEnabled() {
+		t.Fatal("test skipping should be enabled")
+	}
+	if !IsSkippableTest("github.com/DataDog/app.TestA") || !IsSkippableTest("github.com/DataDog/app/sub.TestC") {
+		t.Fatalf("unexpected skippable tests: %v", tests)
+	}
+	if IsSkippableTest("github.com/DataDog/app.TestB") {
+		t.Fatal("TestB should not be skippable")
+	}
+}
+
+func TestTestNames(t *testing.T) {
+	// The tests are looked up with the names built by utils.TestFQN on the test side.
+	suite, name := "github.com/DataDog/app", "TestA/sub"
+	fqn := utils.TestFQN(suite, name)
+
+	known := KnownTests{suite: {suite: {name}}}
+	if names := known.FullyQualifiedNames(); len(names) != 1 || !names[fqn] {
+		t.Fatalf("unexpected known tests: %v", names)
+	}
+
+	var response skippableTestsResponse
+	body := `{"data":[{"type":"test","attributes":{"suite":"` + suite + `","name":"` + name + `"}}]}`
+	if err := json.Unmarshal([]byte(body), &response); err != nil {
+		t.Fatal(err)
+	}
+	if tests := response.skippableTests(); len(tests) != 1 || !tests[fqn] {
+		t.Fatalf("unexpected skippable tests: %v", tests)
+	}
+}
+
+func TestEarlyFlakeDetectionExecutions(t *testing.T) {
+	expected := map[time.Duration]int{
+		time.Second:      10,
+		8 * time.Second:  5,
+		20 * time.Second: 3,
+		time.Minute:      2,
+		time.Hour:        1,
+	}
+	for duration, executions := range expected {
+		if actual := EarlyFlakeDetectionExecutions(duration); actual != executions {
+			t.Fatalf("duration %s: expected %d executions, actual %d", duration, executions, actual)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package settings
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/utils"
+)
+
+// SkippableTests contains the fully qualified names of the tests that can be skipped.
+type SkippableTests map[string]bool
+
+type skippableTestsRequest struct {
+	Request
+	TestLevel string `json:"test_level"`
+}
+
+type skippableTestsResponse struct {
+	Data []struct {
+		Type       string `json:"type"`
+		Attributes struct {
+			Suite string `json:"suite"`
+			Name  string `json:"name"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+func (r skippableTestsResponse) skippableTests() SkippableTests {
+	tests := SkippableTests{}
+	for _, item := range r.Data {
+		if item.Type == "test" {
+			tests[utils.TestFQN(item.Attributes.Suite, item.Attributes.Name)] = true
+		}
+	}
+	return tests
+}
+
+// LoadSkippableTestsFile reads a skippable tests list from a JSON file with the same format
+// as the response of the API:
+//
+//	{"data": [{"type": "test", "attributes": {"suite": "suite", "name": "TestName"}}]}
+func LoadSkippableTestsFile(path string) (SkippableTests, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var response skippableTestsResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, err
+	}
+	return response.skippableTests(), nil
+}
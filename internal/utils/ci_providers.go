@@ -8,9 +8,11 @@ package utils
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/DataDog/dd-sdk-go-testing/internal/constants"
@@ -95,6 +97,9 @@ func normalizeTags(tags map[string]string) {
 	if tag, ok := tags[constants.GitTag]; ok && tag != "" {
 		tags[constants.GitTag] = normalizeRef(tag)
 	}
+	if tag, ok := tags[constants.GitPrBaseBranch]; ok && tag != "" {
+		tags[constants.GitPrBaseBranch] = normalizeRef(tag)
+	}
 	if tag, ok := tags[constants.GitRepositoryURL]; ok && tag != "" {
 		tags[constants.GitRepositoryURL] = filterSensitiveInfo(tag)
 	}
@@ -162,6 +167,18 @@ func firstEnv(keys ...string) string {
 	return ""
 }
 
+// setPullRequestTags sets the number, base branch, base commit and head commit of the pull
+// request built, if any. The unknown values are empty.
+func setPullRequestTags(tags map[string]string, number, baseBranch, baseCommit, headCommit string) {
+	if number == "" || number == "false" {
+		return
+	}
+	tags[constants.PrNumber] = number
+	tags[constants.GitPrBaseBranch] = baseBranch
+	tags[constants.GitPrBaseCommit] = baseCommit
+	tags[constants.GitHeadCommit] = headCommit
+}
+
 func extractAppveyor() map[string]string {
 	tags := map[string]string{}
 	url := fmt.Sprintf("https://ci.appveyor.com/project/%s/builds/%s", os.Getenv("APPVEYOR_REPO_NAME"), os.Getenv("APPVEYOR_BUILD_ID"))
@@ -222,6 +239,12 @@ func extractAzurePipelines() map[string]string {
 	tags[constants.GitCommitAuthorName] = os.Getenv("BUILD_REQUESTEDFORID")
 	tags[constants.GitCommitAuthorEmail] = os.Getenv("BUILD_REQUESTEDFOREMAIL")
 
+	setPullRequestTags(tags,
+		firstEnv("SYSTEM_PULLREQUEST_PULLREQUESTNUMBER", "SYSTEM_PULLREQUEST_PULLREQUESTID"),
+		os.Getenv("SYSTEM_PULLREQUEST_TARGETBRANCH"),
+		"",
+		os.Getenv("SYSTEM_PULLREQUEST_SOURCECOMMITID"))
+
 	jsonString, err := getEnvVarsJson("SYSTEM_TEAMPROJECTID", "BUILD_BUILDID", "SYSTEM_JOBID")
 	if err == nil {
 		tags[constants.CIEnvVars] = string(jsonString)
@@ -260,6 +283,12 @@ func extractBitbucket() map[string]string {
 	tags[constants.CIPipelineName] = os.Getenv("BITBUCKET_REPO_FULL_NAME")
 	tags[constants.CIPipelineURL] = url
 	tags[constants.CIJobURL] = url
+
+	setPullRequestTags(tags,
+		os.Getenv("BITBUCKET_PR_ID"),
+		os.Getenv("BITBUCKET_PR_DESTINATION_BRANCH"),
+		os.Getenv("BITBUCKET_PR_DESTINATION_COMMIT"),
+		os.Getenv("BITBUCKET_COMMIT"))
 	return tags
 }
 
@@ -298,6 +327,13 @@ func extractBuildkite() map[string]string {
 	tags[constants.GitCommitAuthorEmail] = os.Getenv("BUILDKITE_BUILD_AUTHOR_EMAIL")
 	tags[constants.CINodeName] = os.Getenv("BUILDKITE_AGENT_ID")
 
+	// BUILDKITE_PULL_REQUEST is false when not building a pull request.
+	setPullRequestTags(tags,
+		os.Getenv("BUILDKITE_PULL_REQUEST"),
+		os.Getenv("BUILDKITE_PULL_REQUEST_BASE_BRANCH"),
+		"",
+		os.Getenv("BUILDKITE_COMMIT"))
+
 	jsonString, err := getEnvVarsJson("BUILDKITE_BUILD_ID", "BUILDKITE_JOB_ID")
 	if err == nil {
 		tags[constants.CIEnvVars] = string(jsonString)
@@ -395,9 +431,47 @@ func extractGithubActions() map[string]string {
 		tags[constants.CIEnvVars] = string(jsonString)
 	}
 
+	// GITHUB_SHA is the merge commit of a pull request, its head commit is in the event payload.
+	if event, ok := readGithubEvent(os.Getenv("GITHUB_EVENT_PATH")); ok && event.PullRequest != nil {
+		pr := event.PullRequest
+		baseBranch := os.Getenv("GITHUB_BASE_REF")
+		if baseBranch == "" {
+			baseBranch = pr.Base.Ref
+		}
+		setPullRequestTags(tags, strconv.Itoa(pr.Number), baseBranch, pr.Base.Sha, pr.Head.Sha)
+	}
+
 	return tags
 }
 
+// githubEvent is the part of the payload of the event triggering a GitHub Actions workflow
+// describing the pull request.
+type githubEvent struct {
+	PullRequest *struct {
+		Number int `json:"number"`
+		Base   struct {
+			Ref string `json:"ref"`
+			Sha string `json:"sha"`
+		} `json:"base"`
+		Head struct {
+			Sha string `json:"sha"`
+		} `json:"head"`
+	} `json:"pull_request"`
+}
+
+// readGithubEvent reads the payload of the event triggering the workflow.
+func readGithubEvent(path string) (githubEvent, bool) {
+	var event githubEvent
+	if path == "" {
+		return event, false
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return event, false
+	}
+	return event, json.Unmarshal(data, &event) == nil
+}
+
 func extractGitlab() map[string]string {
 	tags := map[string]string{}
 	url := os.Getenv("CI_PIPELINE_URL")
@@ -427,6 +501,12 @@ func extractGitlab() map[string]string {
 	tags[constants.GitCommitAuthorEmail] = strings.TrimSpace(authorArray[1])
 	tags[constants.GitCommitAuthorDate] = os.Getenv("CI_COMMIT_TIMESTAMP")
 
+	setPullRequestTags(tags,
+		os.Getenv("CI_MERGE_REQUEST_IID"),
+		os.Getenv("CI_MERGE_REQUEST_TARGET_BRANCH_NAME"),
+		firstEnv("CI_MERGE_REQUEST_TARGET_BRANCH_SHA", "CI_MERGE_REQUEST_DIFF_BASE_SHA"),
+		os.Getenv("CI_MERGE_REQUEST_SOURCE_BRANCH_SHA"))
+
 	jsonString, err := getEnvVarsJson("CI_PROJECT_URL", "CI_PIPELINE_ID", "CI_JOB_ID")
 	if err == nil {
 		tags[constants.CIEnvVars] = string(jsonString)
@@ -466,6 +546,9 @@ func extractJenkins() map[string]string {
 	tags[constants.CIPipelineURL] = os.Getenv("BUILD_URL")
 	tags[constants.CINodeName] = os.Getenv("NODE_NAME")
 
+	// The pull requests of multibranch pipelines have no commit variables.
+	setPullRequestTags(tags, os.Getenv("CHANGE_ID"), os.Getenv("CHANGE_TARGET"), "", "")
+
 	jsonString, err := getEnvVarsJson("DD_CUSTOM_TRACE_ID")
 	if err == nil {
 		tags[constants.CIEnvVars] = string(jsonString)
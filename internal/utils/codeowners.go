@@ -0,0 +1,145 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package utils
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// codeOwnersLocations are the paths where a CODEOWNERS file is looked up, relative to the
+// repository root, in order of precedence.
+var codeOwnersLocations = []string{
+	filepath.Join(".github", "CODEOWNERS"),
+	"CODEOWNERS",
+	filepath.Join("docs", "CODEOWNERS"),
+	filepath.Join(".gitlab", "CODEOWNERS"),
+}
+
+// CodeOwners contains the rules of a GitHub or GitLab CODEOWNERS file.
+type CodeOwners struct {
+	entries []codeOwnersEntry
+}
+
+type codeOwnersEntry struct {
+	pattern *regexp.Regexp
+	owners  []string
+}
+
+// LoadCodeOwners parses the CODEOWNERS file of the repository at root. It returns nil when the
+// repository has no CODEOWNERS file.
+func LoadCodeOwners(root string) (*CodeOwners, error) {
+	for _, location := range codeOwnersLocations {
+		f, err := os.Open(filepath.Join(root, location))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return ParseCodeOwners(f)
+	}
+	return nil, nil
+}
+
+// ParseCodeOwners parses the rules of a CODEOWNERS file. Each rule is a path pattern followed
+// by its owners; comments, blank lines and GitLab section headers are ignored.
+func ParseCodeOwners(r io.Reader) (*CodeOwners, error) {
+	c := &CodeOwners{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// GitLab sections: [Section] or ^[Optional section], optionally followed by default owners.
+		if strings.HasPrefix(line, "[") || strings.HasPrefix(line, "^[") {
+			continue
+		}
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(strings.ReplaceAll(line, `\ `, "\x00"))
+		pattern := strings.ReplaceAll(fields[0], "\x00", " ")
+		c.entries = append(c.entries, codeOwnersEntry{
+			pattern: codeOwnersPattern(pattern),
+			owners:  fields[1:],
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Match returns the owners of a slash-separated path relative to the repository root. The last
+// matching rule wins, as in GitHub and GitLab.
+func (c *CodeOwners) Match(path string) []string {
+	if c == nil {
+		return nil
+	}
+	path = strings.TrimPrefix(filepath.ToSlash(path), "/")
+	for i := len(c.entries) - 1; i >= 0; i-- {
+		if c.entries[i].pattern.MatchString(path) {
+			return c.entries[i].owners
+		}
+	}
+	return nil
+}
+
+// codeOwnersPattern converts a gitignore-style pattern to a regular expression:
+//
+//   - a pattern starting with or containing a slash is relative to the repository root,
+//     otherwise it matches at any depth;
+//   - a pattern ending with a slash only matches directories;
+//   - "*" matches anything but a slash, "?" a single character other than a slash, and "**"
+//     matches any number of directories;
+//   - a pattern naming a directory, without wildcard in its last segment, matches all the files
+//     the directory contains, while "*" in the last segment doesn't match the subdirectories.
+func codeOwnersPattern(pattern string) *regexp.Regexp {
+	dirOnly := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	var expr strings.Builder
+	if anchored {
+		expr.WriteString("^")
+	} else {
+		expr.WriteString("^(?:.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			expr.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			expr.WriteString("[^/]*")
+		case pattern[i] == '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	last := pattern[strings.LastIndex(pattern, "/")+1:]
+	switch {
+	case dirOnly:
+		expr.WriteString("/.*$")
+	case !strings.ContainsAny(last, "*?"):
+		expr.WriteString("(?:/.*)?$")
+	default:
+		expr.WriteString("$")
+	}
+	return regexp.MustCompile(expr.String())
+}
@@ -0,0 +1,86 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const codeOwners = `# Default owners
+*                   @org/everyone
+
+*.md                @org/docs # documentation
+/internal/          @org/core
+internal/utils/*.go @org/utils
+**/testdata/**      @org/qa
+/cmd/tool           @org/tools
+/docs/*             @org/writers
+
+[Section]
+/autoinstrument/    @org/ci @someone
+/vendor/
+`
+
+func TestCodeOwnersMatch(t *testing.T) {
+	c, err := ParseCodeOwners(strings.NewReader(codeOwners))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"init.go":                               "@org/everyone",
+		"README.md":                             "@org/docs",
+		"internal/README.md":                    "@org/core",
+		"internal/constants/ci.go":              "@org/core",
+		"internal/utils/git.go":                 "@org/utils",
+		"internal/utils/sub/a.go":               "@org/core",
+		"internal/utils/testdata/fixtures/a":    "@org/qa",
+		"cmd/tool/main.go":                      "@org/tools",
+		"cmd/tool":                              "@org/tools",
+		"cmd/toolbox/main.go":                   "@org/everyone",
+		"autoinstrument/init.go":                "@org/ci @someone",
+		"vendor/github.com/tinylib/msgp/a.go":   "",
+		"docs/autoinstrument/guide/README.md":   "@org/docs",
+		"docs/a.go":                             "@org/writers",
+		"docs/a/b.go":                           "@org/everyone",
+		"/internal/agentless/agentless_test.go": "@org/core",
+	}
+	for path, owners := range expected {
+		if actual := strings.Join(c.Match(path), " "); actual != owners {
+			t.Fatalf("%s: expected %q, actual %q", path, owners, actual)
+		}
+	}
+}
+
+func TestLoadCodeOwners(t *testing.T) {
+	root, err := ioutil.TempDir("", "codeowners")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	if c, err := LoadCodeOwners(root); c != nil || err != nil {
+		t.Fatalf("unexpected result without CODEOWNERS file: %v, %v", c, err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(root, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "docs", "CODEOWNERS"), []byte("* @org/docs\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadCodeOwners(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owners := c.Match("main.go"); len(owners) != 1 || owners[0] != "@org/docs" {
+		t.Fatalf("unexpected owners: %v", owners)
+	}
+}
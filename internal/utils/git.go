@@ -6,7 +6,9 @@
 package utils
 
 import (
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -16,6 +18,7 @@ type LocalGitData struct {
 	SourceRoot     string
 	RepositoryUrl  string
 	Branch         string
+	Tag            string
 	CommitSha      string
 	AuthorDate     time.Time
 	AuthorName     string
@@ -26,16 +29,73 @@ type LocalGitData struct {
 	CommitMessage  string
 }
 
-// LocalGetGitData get the git data from the HEAD in Git repository
+// LocalGetGitData get the git data from the HEAD in Git repository. The .git directory is read
+// directly, falling back to the git command if it can't be parsed.
 func LocalGetGitData() (LocalGitData, error) {
+	if gitData, err := readGitData(); err == nil {
+		return gitData, nil
+	}
+	return commandGitData()
+}
+
+// readGitData reads the git data from the .git directory of the working directory.
+func readGitData() (LocalGitData, error) {
 	gitData := LocalGitData{}
 
-	// Extract git working folder
-	out, err := exec.Command("git", "rev-parse", "--absolute-git-dir").Output()
+	wd, err := os.Getwd()
+	if err != nil {
+		return gitData, err
+	}
+	repo, err := openGitRepository(wd)
 	if err != nil {
 		return gitData, err
 	}
-	gitData.SourceRoot = strings.ReplaceAll(strings.Trim(string(out), "\n"), ".git", "")
+	defer repo.Close()
+
+	ref, hash, err := repo.Head()
+	if err != nil {
+		return gitData, err
+	}
+	commit, err := repo.Commit(hash)
+	if err != nil {
+		return gitData, err
+	}
+
+	// A detached HEAD has no branch, as reported by git rev-parse --abbrev-ref HEAD.
+	gitData.Branch = "HEAD"
+	if ref != "" {
+		gitData.Branch = strings.TrimPrefix(ref, "refs/heads/")
+	}
+	if url, err := repo.RemoteURL(gitData.Branch); err == nil {
+		gitData.RepositoryUrl = filterSensitiveInfo(url)
+	}
+	if tags, err := repo.TagsAt(hash); err == nil && len(tags) > 0 {
+		gitData.Tag = tags[0]
+	}
+
+	gitData.SourceRoot = repo.workTree
+	gitData.CommitSha = commit.Hash
+	gitData.AuthorDate = commit.Author.When
+	gitData.AuthorName = commit.Author.Name
+	gitData.AuthorEmail = commit.Author.Email
+	gitData.CommitterDate = commit.Committer.When
+	gitData.CommitterName = commit.Committer.Name
+	gitData.CommitterEmail = commit.Committer.Email
+	gitData.CommitMessage = strings.Trim(commit.Message, "\n")
+
+	return gitData, nil
+}
+
+// commandGitData gets the git data with the git command.
+func commandGitData() (LocalGitData, error) {
+	gitData := LocalGitData{}
+
+	// Extract git working folder, which holds the .git file of a linked work tree or submodule
+	out, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return gitData, err
+	}
+	gitData.SourceRoot = filepath.Clean(strings.Trim(string(out), "\n"))
 
 	// Extract repository data
 	out, err = exec.Command("git", "ls-remote", "--get-url").Output()
@@ -51,6 +111,11 @@ func LocalGetGitData() (LocalGitData, error) {
 	}
 	gitData.Branch = strings.Trim(string(out), "\n")
 
+	// Extract the first tag pointing at HEAD
+	if out, err = exec.Command("git", "tag", "--points-at", "HEAD").Output(); err == nil {
+		gitData.Tag = strings.SplitN(strings.Trim(string(out), "\n"), "\n", 2)[0]
+	}
+
 	// Get remaining data from the git log command: git log -1 --pretty='%H","%aI","%an","%ae","%cI","%cn","%ce","%B'
 	out, err = exec.Command("git", "log", "-1", "--pretty=%H\",\"%at\",\"%an\",\"%ae\",\"%ct\",\"%cn\",\"%ce\",\"%B").Output()
 	if err != nil {
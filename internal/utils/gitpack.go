@@ -0,0 +1,378 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// gitObjectType is the type of an object, as numbered in the pack files.
+type gitObjectType byte
+
+const (
+	gitObjectCommit   gitObjectType = 1
+	gitObjectTree     gitObjectType = 2
+	gitObjectBlob     gitObjectType = 3
+	gitObjectTag      gitObjectType = 4
+	gitObjectOfsDelta gitObjectType = 6
+	gitObjectRefDelta gitObjectType = 7
+)
+
+// gitObjectTypes maps the names of the object types in the headers of the loose objects to
+// their type.
+var gitObjectTypes = map[string]gitObjectType{
+	"commit": gitObjectCommit,
+	"tree":   gitObjectTree,
+	"blob":   gitObjectBlob,
+	"tag":    gitObjectTag,
+}
+
+// gitPackIndexMagic starts the version 2 pack index files.
+var gitPackIndexMagic = []byte{0xff, 't', 'O', 'c', 0, 0, 0, 2}
+
+const (
+	gitHashSize = 20
+
+	// The offsets of the tables of a version 2 pack index file.
+	gitPackFanoutOffset = 8
+	gitPackHashesOffset = gitPackFanoutOffset + 256*4
+)
+
+// gitPack is a pack file and its index. The index is read on demand, so that looking up an
+// object doesn't load the whole index of a big repository.
+type gitPack struct {
+	index *os.File
+	pack  *os.File
+	count uint32
+}
+
+// openGitPacks opens the pack files of a pack directory.
+func openGitPacks(dir string) ([]*gitPack, error) {
+	indexes, err := filepath.Glob(filepath.Join(dir, "pack-*.idx"))
+	if err != nil {
+		return nil, err
+	}
+	packs := make([]*gitPack, 0, len(indexes))
+	for _, index := range indexes {
+		pack, err := openGitPack(index)
+		if err != nil {
+			for _, p := range packs {
+				p.Close()
+			}
+			return nil, err
+		}
+		packs = append(packs, pack)
+	}
+	return packs, nil
+}
+
+func openGitPack(indexPath string) (*gitPack, error) {
+	index, err := os.Open(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, gitPackHashesOffset)
+	if _, err := index.ReadAt(header, 0); err != nil {
+		index.Close()
+		return nil, err
+	}
+	if !bytes.Equal(header[:len(gitPackIndexMagic)], gitPackIndexMagic) {
+		index.Close()
+		return nil, fmt.Errorf("unsupported pack index: %s", indexPath)
+	}
+
+	pack, err := os.Open(strings.TrimSuffix(indexPath, ".idx") + ".pack")
+	if err != nil {
+		index.Close()
+		return nil, err
+	}
+	return &gitPack{
+		index: index,
+		pack:  pack,
+		count: binary.BigEndian.Uint32(header[gitPackHashesOffset-4:]),
+	}, nil
+}
+
+// Close closes the pack file and its index.
+func (p *gitPack) Close() error {
+	err := p.index.Close()
+	if e := p.pack.Close(); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
+
+// find returns the offset of an object in the pack file, with a binary search in the sorted
+// hashes of the index between the bounds of the fanout table for the first byte of the hash.
+func (p *gitPack) find(hash string) (int64, bool, error) {
+	want, err := hex.DecodeString(hash)
+	if err != nil || len(want) != gitHashSize {
+		return 0, false, fmt.Errorf("invalid object name: %s", hash)
+	}
+
+	var lo, hi uint32
+	bounds := make([]byte, 8)
+	if want[0] == 0 {
+		if _, err := p.index.ReadAt(bounds[4:], gitPackFanoutOffset); err != nil {
+			return 0, false, err
+		}
+	} else if _, err := p.index.ReadAt(bounds, gitPackFanoutOffset+int64(want[0]-1)*4); err != nil {
+		return 0, false, err
+	}
+	lo, hi = binary.BigEndian.Uint32(bounds), binary.BigEndian.Uint32(bounds[4:])
+
+	got := make([]byte, gitHashSize)
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		if _, err := p.index.ReadAt(got, gitPackHashesOffset+int64(mid)*gitHashSize); err != nil {
+			return 0, false, err
+		}
+		switch bytes.Compare(got, want) {
+		case 0:
+			offset, err := p.offset(mid)
+			return offset, err == nil, err
+		case -1:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
+	}
+	return 0, false, nil
+}
+
+// offset returns the offset in the pack file of the i-th object of the index. The offsets over
+// 2GB are in a table of 64-bit offsets following the table of 32-bit offsets.
+func (p *gitPack) offset(i uint32) (int64, error) {
+	offsets := int64(gitPackHashesOffset) + int64(p.count)*(gitHashSize+4)
+	buf := make([]byte, 8)
+	if _, err := p.index.ReadAt(buf[:4], offsets+int64(i)*4); err != nil {
+		return 0, err
+	}
+	offset := binary.BigEndian.Uint32(buf)
+	if offset&0x80000000 == 0 {
+		return int64(offset), nil
+	}
+
+	large := offsets + int64(p.count)*4 + int64(offset&0x7fffffff)*8
+	if _, err := p.index.ReadAt(buf, large); err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(buf)), nil
+}
+
+// readObject returns the type and content of the object at an offset of the pack file, applying
+// the deltas to their base object. The bases referenced by hash are read with readBase, as they
+// may be in another pack.
+func (p *gitPack) readObject(offset int64, readBase func(hash string) (gitObjectType, []byte, error)) (gitObjectType, []byte, error) {
+	r := bufio.NewReader(io.NewSectionReader(p.pack, offset, 1<<62))
+	typ, size, err := readGitPackHeader(r)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var baseType gitObjectType
+	var base []byte
+	switch typ {
+	case gitObjectCommit, gitObjectTree, gitObjectBlob, gitObjectTag:
+		data, err := readGitPackData(r, size)
+		return typ, data, err
+	case gitObjectOfsDelta:
+		distance, err := readGitOffset(r)
+		if err != nil {
+			return 0, nil, err
+		}
+		if distance <= 0 || distance > offset {
+			return 0, nil, errors.New("invalid delta base offset")
+		}
+		baseType, base, err = p.readObject(offset-distance, readBase)
+		if err != nil {
+			return 0, nil, err
+		}
+	case gitObjectRefDelta:
+		hash := make([]byte, gitHashSize)
+		if _, err := io.ReadFull(r, hash); err != nil {
+			return 0, nil, err
+		}
+		baseType, base, err = readBase(hex.EncodeToString(hash))
+		if err != nil {
+			return 0, nil, err
+		}
+	default:
+		return 0, nil, fmt.Errorf("invalid packed object type: %d", typ)
+	}
+
+	delta, err := readGitPackData(r, size)
+	if err != nil {
+		return 0, nil, err
+	}
+	data, err := applyGitDelta(base, delta)
+	return baseType, data, err
+}
+
+// objectType returns the type of the object at an offset of the pack file, reading only the
+// headers of the object and of its delta bases. The type of the bases referenced by hash is
+// read with baseType, as they may be in another pack.
+func (p *gitPack) objectType(offset int64, baseType func(hash string) (gitObjectType, error)) (gitObjectType, error) {
+	r := bufio.NewReader(io.NewSectionReader(p.pack, offset, 1<<62))
+	typ, _, err := readGitPackHeader(r)
+	if err != nil {
+		return 0, err
+	}
+	switch typ {
+	case gitObjectCommit, gitObjectTree, gitObjectBlob, gitObjectTag:
+		return typ, nil
+	case gitObjectOfsDelta:
+		distance, err := readGitOffset(r)
+		if err != nil {
+			return 0, err
+		}
+		if distance <= 0 || distance > offset {
+			return 0, errors.New("invalid delta base offset")
+		}
+		return p.objectType(offset-distance, baseType)
+	case gitObjectRefDelta:
+		hash := make([]byte, gitHashSize)
+		if _, err := io.ReadFull(r, hash); err != nil {
+			return 0, err
+		}
+		return baseType(hex.EncodeToString(hash))
+	default:
+		return 0, fmt.Errorf("invalid packed object type: %d", typ)
+	}
+}
+
+// readGitPackHeader reads the type and size of a packed object: the first byte holds the type
+// in bits 4 to 6 and the low 4 bits of the size, followed by 7 bits of the size per byte while
+// the most significant bit is set.
+func readGitPackHeader(r io.ByteReader) (gitObjectType, int64, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	typ := gitObjectType(c >> 4 & 7)
+	size := int64(c & 0x0f)
+	for shift := uint(4); c&0x80 != 0; shift += 7 {
+		if c, err = r.ReadByte(); err != nil {
+			return 0, 0, err
+		}
+		size |= int64(c&0x7f) << shift
+	}
+	return typ, size, nil
+}
+
+// readGitOffset reads the distance to the base of an offset delta, encoded with 7 bits per byte
+// from the most significant, adding one to all bytes but the last.
+func readGitOffset(r io.ByteReader) (int64, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	offset := int64(c & 0x7f)
+	for c&0x80 != 0 {
+		if c, err = r.ReadByte(); err != nil {
+			return 0, err
+		}
+		offset = (offset+1)<<7 | int64(c&0x7f)
+	}
+	return offset, nil
+}
+
+// readGitPackData reads the zlib compressed data of a packed object.
+func readGitPackData(r io.Reader, size int64) ([]byte, error) {
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(zr, size))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != size {
+		return nil, errors.New("invalid packed object size")
+	}
+	return data, nil
+}
+
+// applyGitDelta returns the object built by a delta from its base. The delta starts with the
+// sizes of the base and result, followed by the instructions copying a range of the base or
+// inserting the bytes following the instruction.
+func applyGitDelta(base, delta []byte) ([]byte, error) {
+	r := bytes.NewReader(delta)
+	baseSize, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if baseSize != uint64(len(base)) {
+		return nil, errors.New("invalid delta base size")
+	}
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, size)
+	for {
+		op, err := r.ReadByte()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if op&0x80 == 0 {
+			if op == 0 {
+				return nil, errors.New("invalid delta instruction")
+			}
+			insert := make([]byte, op)
+			if _, err := io.ReadFull(r, insert); err != nil {
+				return nil, err
+			}
+			out = append(out, insert...)
+			continue
+		}
+
+		// The bits 0 to 3 of a copy tell which bytes of the offset follow, and the bits 4 to 6
+		// which bytes of the size, from the least significant.
+		var offset, length uint32
+		for i := uint(0); i < 7; i++ {
+			if op&(1<<i) == 0 {
+				continue
+			}
+			c, err := r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			if i < 4 {
+				offset |= uint32(c) << (8 * i)
+			} else {
+				length |= uint32(c) << (8 * (i - 4))
+			}
+		}
+		if length == 0 {
+			length = 0x10000
+		}
+		if uint64(offset)+uint64(length) > uint64(len(base)) {
+			return nil, errors.New("invalid delta copy")
+		}
+		out = append(out, base[offset:offset+length]...)
+	}
+	if uint64(len(out)) != size {
+		return nil, errors.New("invalid delta result size")
+	}
+	return out, nil
+}
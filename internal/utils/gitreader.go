@@ -0,0 +1,606 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSymrefDepth is the number of symbolic refs followed before giving up, as git does.
+const maxSymrefDepth = 5
+
+// gitRepository reads the metadata of a Git repository from its .git directory, without the git
+// binary.
+type gitRepository struct {
+	// workTree is the directory holding the .git directory or file.
+	workTree string
+
+	// gitDir is the git directory of the work tree, holding HEAD.
+	gitDir string
+
+	// commonDir is the directory holding the objects, refs and config, which is the git
+	// directory of the main work tree for a linked work tree.
+	commonDir string
+
+	// packs are the pack files of the object database, opened on the first lookup of an object
+	// that isn't loose.
+	packs []*gitPack
+}
+
+// gitCommit is a commit object.
+type gitCommit struct {
+	Hash      string
+	Tree      string
+	Parents   []string
+	Author    gitSignature
+	Committer gitSignature
+	Message   string
+}
+
+// gitSignature is the author or committer of a commit.
+type gitSignature struct {
+	Name  string
+	Email string
+	When  time.Time
+}
+
+// openGitRepository opens the repository of dir, looking for a .git directory or file in dir
+// and its parents.
+func openGitRepository(dir string) (*gitRepository, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		path := filepath.Join(dir, ".git")
+		if info, err := os.Stat(path); err == nil {
+			gitDir := path
+			if !info.IsDir() {
+				if gitDir, err = readGitDirFile(path); err != nil {
+					return nil, err
+				}
+			}
+			return newGitRepository(dir, gitDir)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, errors.New("not a git repository")
+		}
+		dir = parent
+	}
+}
+
+// readGitDirFile returns the git directory a .git file points to, as in linked work trees and
+// submodules.
+func readGitDirFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	line := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(line, "gitdir:") {
+		return "", fmt.Errorf("invalid .git file: %s", path)
+	}
+	gitDir := strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(path), gitDir)
+	}
+	return filepath.Clean(gitDir), nil
+}
+
+func newGitRepository(workTree, gitDir string) (*gitRepository, error) {
+	if _, err := os.Stat(filepath.Join(gitDir, "HEAD")); err != nil {
+		return nil, err
+	}
+	r := &gitRepository{workTree: workTree, gitDir: gitDir, commonDir: gitDir}
+	if data, err := ioutil.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir := strings.TrimSpace(string(data))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+		r.commonDir = filepath.Clean(commonDir)
+	}
+	return r, nil
+}
+
+// Close closes the pack files of the repository.
+func (r *gitRepository) Close() error {
+	var err error
+	for _, pack := range r.packs {
+		if e := pack.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	r.packs = nil
+	return err
+}
+
+// Head returns the ref HEAD points to, or an empty ref if HEAD is detached, and the hash of the
+// commit checked out.
+func (r *gitRepository) Head() (ref string, hash string, err error) {
+	data, err := ioutil.ReadFile(filepath.Join(r.gitDir, "HEAD"))
+	if err != nil {
+		return "", "", err
+	}
+	head := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(head, "ref:") {
+		return "", head, nil
+	}
+	ref = strings.TrimSpace(strings.TrimPrefix(head, "ref:"))
+	hash, err = r.ResolveRef(ref)
+	return ref, hash, err
+}
+
+// ResolveRef returns the hash a ref points to, following the symbolic refs.
+func (r *gitRepository) ResolveRef(ref string) (string, error) {
+	for i := 0; i < maxSymrefDepth; i++ {
+		value, err := r.readRef(ref)
+		if err != nil {
+			return "", err
+		}
+		if !strings.HasPrefix(value, "ref:") {
+			return value, nil
+		}
+		ref = strings.TrimSpace(strings.TrimPrefix(value, "ref:"))
+	}
+	return "", fmt.Errorf("too many levels of symbolic refs: %s", ref)
+}
+
+// readRef returns the content of a loose ref, or the hash of a packed ref.
+func (r *gitRepository) readRef(ref string) (string, error) {
+	// The pseudo refs and the refs of a linked work tree are in its git directory, the other
+	// refs are shared.
+	dirs := []string{r.commonDir}
+	if r.gitDir != r.commonDir {
+		dirs = []string{r.gitDir, r.commonDir}
+	}
+	for _, dir := range dirs {
+		if data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(ref))); err == nil {
+			return strings.TrimSpace(string(data)), nil
+		}
+	}
+
+	refs, _, err := r.packedRefs()
+	if err != nil {
+		return "", err
+	}
+	if hash, ok := refs[ref]; ok {
+		return hash, nil
+	}
+	return "", fmt.Errorf("ref not found: %s", ref)
+}
+
+// packedRefs returns the hashes of the refs in the packed-refs file, and the hashes of the
+// objects the annotated tags point to if the file has them. If the file is fully peeled, the
+// refs that aren't annotated tags are peeled to their own hash.
+func (r *gitRepository) packedRefs() (refs map[string]string, peeled map[string]string, err error) {
+	refs, peeled = map[string]string{}, map[string]string{}
+	f, err := os.Open(filepath.Join(r.commonDir, "packed-refs"))
+	if os.IsNotExist(err) {
+		return refs, peeled, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	last, fullyPeeled := "", false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		if line[0] == '#' {
+			// The header lists the traits of the file: # pack-refs with: peeled fully-peeled
+			for _, trait := range strings.Fields(line) {
+				fullyPeeled = fullyPeeled || trait == "fully-peeled"
+			}
+			continue
+		}
+		// The peeled hash of an annotated tag follows its ref.
+		if line[0] == '^' {
+			if last != "" {
+				peeled[last] = line[1:]
+			}
+			continue
+		}
+		if fields := strings.Fields(line); len(fields) == 2 {
+			refs[fields[1]] = fields[0]
+			last = fields[1]
+		}
+	}
+	if fullyPeeled {
+		for ref, hash := range refs {
+			if _, ok := peeled[ref]; !ok {
+				peeled[ref] = hash
+			}
+		}
+	}
+	return refs, peeled, scanner.Err()
+}
+
+// TagsAt returns the sorted names of the tags pointing at a commit, lightweight or annotated.
+func (r *gitRepository) TagsAt(hash string) ([]string, error) {
+	refs, peeled, err := r.packedRefs()
+	if err != nil {
+		return nil, err
+	}
+	tags := map[string]string{}
+	for ref, target := range refs {
+		if strings.HasPrefix(ref, "refs/tags/") {
+			tags[ref] = target
+		}
+	}
+
+	// The loose refs override the packed refs.
+	root := filepath.Join(r.commonDir, "refs", "tags")
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(r.commonDir, path)
+		if err != nil {
+			return err
+		}
+		ref := filepath.ToSlash(rel)
+		tags[ref] = strings.TrimSpace(string(data))
+		delete(peeled, ref)
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var names []string
+	for ref, target := range tags {
+		if p, ok := peeled[ref]; ok {
+			target = p
+		} else if target != hash {
+			// Only the annotated tags need to be peeled, so the other objects aren't read.
+			if typ, err := r.objectType(target); err != nil || typ != gitObjectTag {
+				continue
+			}
+			if target, err = r.peelTag(target); err != nil {
+				continue
+			}
+		}
+		if target == hash {
+			names = append(names, strings.TrimPrefix(ref, "refs/tags/"))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// peelTag returns the hash of the object an annotated tag points to, following the tags of
+// tags, or the hash itself if it isn't a tag.
+func (r *gitRepository) peelTag(hash string) (string, error) {
+	for i := 0; i < maxSymrefDepth; i++ {
+		typ, data, err := r.readObject(hash)
+		if err != nil {
+			return "", err
+		}
+		if typ != gitObjectTag {
+			return hash, nil
+		}
+		if !bytes.HasPrefix(data, []byte("object ")) || len(data) < len("object ")+40 {
+			return "", fmt.Errorf("invalid tag: %s", hash)
+		}
+		hash = string(data[len("object ") : len("object ")+40])
+	}
+	return "", fmt.Errorf("too many levels of tags: %s", hash)
+}
+
+// RemoteURL returns the URL of the remote of a branch, or of the origin remote if the branch
+// has none, or of the first remote if there is no origin, as git ls-remote --get-url does. Like
+// git, it returns the name of the remote when no remote has a URL.
+func (r *gitRepository) RemoteURL(branch string) (string, error) {
+	config, err := readGitConfig(filepath.Join(r.commonDir, "config"))
+	if err != nil {
+		return "", err
+	}
+	remote := config.get(`branch "` + branch + `".remote`)
+	if remote == "" {
+		remote = "origin"
+	}
+	if url := config.get(`remote "` + remote + `".url`); url != "" {
+		return url, nil
+	}
+	for _, key := range config.keys {
+		if strings.HasPrefix(key, `remote "`) && strings.HasSuffix(key, `".url`) {
+			return config.values[key], nil
+		}
+	}
+	return remote, nil
+}
+
+// gitConfig is the content of a config file. The keys are the section, quoted subsection and
+// variable of the entries, as in `remote "origin".url`, with the section and variable names
+// lowercased.
+type gitConfig struct {
+	keys   []string
+	values map[string]string
+}
+
+func (c gitConfig) get(key string) string {
+	return c.values[key]
+}
+
+// readGitConfig reads the first value of the entries of a config file. The includes are
+// ignored.
+func readGitConfig(path string) (gitConfig, error) {
+	config := gitConfig{values: map[string]string{}}
+	f, err := os.Open(path)
+	if err != nil {
+		return config, err
+	}
+	defer f.Close()
+
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' {
+			end := strings.LastIndexByte(line, ']')
+			if end < 0 {
+				continue
+			}
+			name := strings.TrimSpace(line[1:end])
+			if i := strings.IndexByte(name, '"'); i > 0 {
+				section = strings.ToLower(strings.TrimSpace(name[:i])) + ` ` + name[i:]
+			} else {
+				section = strings.ToLower(name)
+			}
+			continue
+		}
+
+		name, value := line, "true"
+		if i := strings.IndexByte(line, '='); i >= 0 {
+			name, value = strings.TrimSpace(line[:i]), parseGitConfigValue(line[i+1:])
+		}
+		key := section + "." + strings.ToLower(name)
+		if _, ok := config.values[key]; !ok {
+			config.keys = append(config.keys, key)
+			config.values[key] = value
+		}
+	}
+	return config, scanner.Err()
+}
+
+// parseGitConfigValue returns a value of a config file without its comment and quotes.
+func parseGitConfigValue(s string) string {
+	var b strings.Builder
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			quoted = !quoted
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(s[i])
+			}
+		case (c == '#' || c == ';') && !quoted:
+			return strings.TrimSpace(b.String())
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// Commit returns the commit object of a hash.
+func (r *gitRepository) Commit(hash string) (*gitCommit, error) {
+	typ, data, err := r.readObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	if typ != gitObjectCommit {
+		return nil, fmt.Errorf("%s is not a commit", hash)
+	}
+	return parseGitCommit(hash, data)
+}
+
+// readObject returns the type and content of an object, loose or packed.
+func (r *gitRepository) readObject(hash string) (gitObjectType, []byte, error) {
+	if len(hash) != 40 {
+		return 0, nil, fmt.Errorf("invalid object name: %s", hash)
+	}
+	objects := filepath.Join(r.commonDir, "objects")
+	f, err := os.Open(filepath.Join(objects, hash[:2], hash[2:]))
+	if err == nil {
+		defer f.Close()
+		return readLooseObject(f)
+	} else if !os.IsNotExist(err) {
+		return 0, nil, err
+	}
+
+	pack, offset, err := r.findPacked(hash)
+	if err != nil {
+		return 0, nil, err
+	}
+	return pack.readObject(offset, r.readObject)
+}
+
+// objectType returns the type of an object, reading only the header of a loose object or of
+// the packed object and its delta bases.
+func (r *gitRepository) objectType(hash string) (gitObjectType, error) {
+	if len(hash) != 40 {
+		return 0, fmt.Errorf("invalid object name: %s", hash)
+	}
+	f, err := os.Open(filepath.Join(r.commonDir, "objects", hash[:2], hash[2:]))
+	if err == nil {
+		defer f.Close()
+		return readLooseObjectType(f)
+	} else if !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	pack, offset, err := r.findPacked(hash)
+	if err != nil {
+		return 0, err
+	}
+	return pack.objectType(offset, r.objectType)
+}
+
+// findPacked returns the pack holding an object and its offset in the pack file, opening the
+// packs on first use.
+func (r *gitRepository) findPacked(hash string) (*gitPack, int64, error) {
+	var err error
+	if r.packs == nil {
+		if r.packs, err = openGitPacks(filepath.Join(r.commonDir, "objects", "pack")); err != nil {
+			return nil, 0, err
+		}
+	}
+	for _, pack := range r.packs {
+		offset, ok, err := pack.find(hash)
+		if err != nil {
+			return nil, 0, err
+		}
+		if ok {
+			return pack, offset, nil
+		}
+	}
+	return nil, 0, fmt.Errorf("object not found: %s", hash)
+}
+
+// readLooseObject reads a zlib compressed object, with a header made of its type, size and a NUL
+// byte.
+func readLooseObject(f *os.File) (gitObjectType, []byte, error) {
+	zr, err := zlib.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer zr.Close()
+	data, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	end := bytes.IndexByte(data, 0)
+	if end < 0 {
+		return 0, nil, errors.New("invalid object header")
+	}
+	header := strings.Fields(string(data[:end]))
+	if len(header) != 2 {
+		return 0, nil, errors.New("invalid object header")
+	}
+	typ, ok := gitObjectTypes[header[0]]
+	if !ok {
+		return 0, nil, fmt.Errorf("invalid object type: %s", header[0])
+	}
+	data = data[end+1:]
+	if size, err := strconv.Atoi(header[1]); err != nil || size != len(data) {
+		return 0, nil, errors.New("invalid object size")
+	}
+	return typ, data, nil
+}
+
+// readLooseObjectType reads the type of a loose object from its header, without decompressing
+// the rest of the object.
+func readLooseObjectType(f *os.File) (gitObjectType, error) {
+	zr, err := zlib.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return 0, err
+	}
+	defer zr.Close()
+	name, err := bufio.NewReaderSize(zr, 16).ReadString(' ')
+	if err != nil {
+		return 0, errors.New("invalid object header")
+	}
+	name = strings.TrimSuffix(name, " ")
+	typ, ok := gitObjectTypes[name]
+	if !ok {
+		return 0, fmt.Errorf("invalid object type: %s", name)
+	}
+	return typ, nil
+}
+
+// parseGitCommit parses the headers and message of a commit object. The signatures and other
+// multiline headers are skipped.
+func parseGitCommit(hash string, data []byte) (*gitCommit, error) {
+	c := &gitCommit{Hash: hash}
+	text := string(data)
+	for text != "" {
+		var line string
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			line, text = text[:i], text[i+1:]
+		} else {
+			line, text = text, ""
+		}
+		if line == "" {
+			c.Message = text
+			break
+		}
+		if line[0] == ' ' {
+			// Continuation line of a multiline header.
+			continue
+		}
+
+		key, value := line, ""
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			key, value = line[:i], line[i+1:]
+		}
+		switch key {
+		case "tree":
+			c.Tree = value
+		case "parent":
+			c.Parents = append(c.Parents, value)
+		case "author":
+			c.Author = parseGitSignature(value)
+		case "committer":
+			c.Committer = parseGitSignature(value)
+		}
+	}
+	if c.Tree == "" {
+		return nil, fmt.Errorf("invalid commit: %s", hash)
+	}
+	return c, nil
+}
+
+// parseGitSignature parses a signature: name <email> seconds timezone.
+func parseGitSignature(s string) gitSignature {
+	var sig gitSignature
+	start, end := strings.IndexByte(s, '<'), strings.LastIndexByte(s, '>')
+	if start < 0 || end < start {
+		sig.Name = strings.TrimSpace(s)
+		return sig
+	}
+	sig.Name = strings.TrimSpace(s[:start])
+	sig.Email = s[start+1 : end]
+
+	fields := strings.Fields(s[end+1:])
+	if len(fields) == 0 {
+		return sig
+	}
+	seconds, _ := strconv.ParseInt(fields[0], 10, 64)
+	sig.When = time.Unix(seconds, 0)
+	return sig
+}
@@ -6,10 +6,17 @@
 package utils
 
 import (
+	"fmt"
 	"runtime"
 	"strings"
 )
 
+// TestFQN returns the fully qualified name of a test of a suite, used as the resource name of its
+// span and as its key in the known and skippable tests lists.
+func TestFQN(suite, name string) string {
+	return fmt.Sprintf("%s.%s", suite, name)
+}
+
 // GetPackageAndName gets the suite name and test name given a program counter.
 // Uses runtime.FuncForPC internally to get the full func name of the program counter,
 // then it will split the string by the searching for the latest dot ('.') in the string
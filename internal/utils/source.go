@@ -0,0 +1,92 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package utils
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// funcRange is the range of lines of a function declaration or literal.
+type funcRange struct {
+	start, end int
+}
+
+var (
+	// sourceFuncs caches the function ranges of the parsed source files, nil when a file
+	// can't be parsed.
+	sourceFuncs      = map[string][]funcRange{}
+	sourceFuncsMutex sync.Mutex
+)
+
+// GetSourceLocation returns the source file and the first and last lines of the function
+// containing the given program counter. The function is resolved with runtime.FuncForPC and its
+// lines are read from the source file, so closures such as subtests get their own range.
+func GetSourceLocation(pc uintptr) (file string, startLine int, endLine int, ok bool) {
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return "", 0, 0, false
+	}
+	file, line := fn.FileLine(fn.Entry())
+	if file == "" || line == 0 {
+		return "", 0, 0, false
+	}
+
+	// The innermost function containing the first line of the function is the function itself.
+	found := false
+	for _, r := range getFuncRanges(file) {
+		if r.start <= line && line <= r.end && (!found || r.end-r.start < endLine-startLine) {
+			startLine, endLine, found = r.start, r.end, true
+		}
+	}
+	if !found {
+		return file, line, line, true
+	}
+	return file, startLine, endLine, true
+}
+
+// RelativeSourcePath returns the path of file relative to the workspace, or file when it is
+// outside of the workspace.
+func RelativeSourcePath(workspace, file string) string {
+	if workspace == "" {
+		return file
+	}
+	rel, err := filepath.Rel(workspace, file)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return file
+	}
+	return filepath.ToSlash(rel)
+}
+
+func getFuncRanges(file string) []funcRange {
+	sourceFuncsMutex.Lock()
+	defer sourceFuncsMutex.Unlock()
+	if ranges, ok := sourceFuncs[file]; ok {
+		return ranges
+	}
+
+	var ranges []funcRange
+	fset := token.NewFileSet()
+	if f, err := parser.ParseFile(fset, file, nil, 0); err == nil {
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n.(type) {
+			case *ast.FuncDecl, *ast.FuncLit:
+				ranges = append(ranges, funcRange{
+					start: fset.Position(n.Pos()).Line,
+					end:   fset.Position(n.End()).Line,
+				})
+			}
+			return true
+		})
+	}
+	sourceFuncs[file] = ranges
+	return ranges
+}
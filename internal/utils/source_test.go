@@ -0,0 +1,50 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package utils
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func callerPC() uintptr {
+	pc, _, _, _ := runtime.Caller(1)
+	return pc
+}
+
+func TestGetSourceLocation(t *testing.T) {
+	pc := callerPC()
+	file, start, end, ok := GetSourceLocation(pc)
+	if !ok || filepath.Base(file) != "source_test.go" {
+		t.Fatalf("unexpected source file: %s", file)
+	}
+	_, line := runtime.FuncForPC(pc).FileLine(pc)
+	if start >= line || end <= line {
+		t.Fatalf("line %d is not within the test function lines %d-%d", line, start, end)
+	}
+
+	var subStart, subEnd int
+	t.Run("sub", func(t *testing.T) {
+		_, subStart, subEnd, _ = GetSourceLocation(callerPC())
+	})
+	if subStart <= start || subEnd >= end || subEnd-subStart != 2 {
+		t.Fatalf("unexpected subtest lines %d-%d within %d-%d", subStart, subEnd, start, end)
+	}
+}
+
+func TestRelativeSourcePath(t *testing.T) {
+	workspace := filepath.FromSlash("/ws/project")
+	expected := map[string]string{
+		filepath.FromSlash("/ws/project/pkg/a_test.go"): "pkg/a_test.go",
+		filepath.FromSlash("/ws/other/b_test.go"):       filepath.FromSlash("/ws/other/b_test.go"),
+	}
+	for file, rel := range expected {
+		if actual := RelativeSourcePath(workspace, file); actual != rel {
+			t.Fatalf("%s: expected %s, actual %s", file, rel, actual)
+		}
+	}
+}
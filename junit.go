@@ -0,0 +1,287 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package dd_sdk_go_testing
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/constants"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+)
+
+// junitReportEnv names the JUnit XML file written with the tests reported by Run or RunJSON. A
+// relative path is relative to the directory of the tested package, where go test runs the test
+// binary. An existing directory receives a file named after the test module.
+const junitReportEnv = "DD_CIVISIBILITY_JUNIT_REPORT"
+
+var (
+	// junitReport is the JUnit XML file set with SetJUnitReport.
+	junitReport      string
+	junitReportMutex sync.RWMutex
+
+	// junitCases are the test cases of the JUnit report, in the order the tests finished.
+	junitCases      []junitCase
+	junitCasesMutex sync.Mutex
+)
+
+// junitCase is a test recorded for the JUnit report.
+type junitCase struct {
+	suite      string
+	name       string
+	start      time.Time
+	duration   time.Duration
+	status     string
+	errorType  string
+	errorMsg   string
+	errorStack string
+	skipReason string
+}
+
+// junitSpan wraps the span of a test to record the tags of its JUnit test case, whether they're
+// set by the SDK or through the span found in the context of the test.
+type junitSpan struct {
+	ddtrace.Span
+
+	mutex sync.Mutex
+	c     junitCase
+}
+
+// SetJUnitReport sets the JUnit XML file written with the tests reported by Run or RunJSON, in
+// place of DD_CIVISIBILITY_JUNIT_REPORT. An existing directory receives a file named after the test
+// module. An empty path restores the environment variable.
+func SetJUnitReport(path string) {
+	junitReportMutex.Lock()
+	defer junitReportMutex.Unlock()
+	junitReport = path
+}
+
+// junitReportPath returns the JUnit XML file set with SetJUnitReport or junitReportEnv, or an empty
+// string if no report is written.
+func junitReportPath() string {
+	junitReportMutex.RLock()
+	defer junitReportMutex.RUnlock()
+	if junitReport != "" {
+		return junitReport
+	}
+	return os.Getenv(junitReportEnv)
+}
+
+// junitEnabled reports whether a JUnit report is written.
+func junitEnabled() bool {
+	return junitReportPath() != ""
+}
+
+// wrapJUnitSpan returns the span of a test started at the given time, recorded for the JUnit
+// report.
+func wrapJUnitSpan(span ddtrace.Span, suite, name string, start time.Time) ddtrace.Span {
+	return &junitSpan{Span: span, c: junitCase{suite: suite, name: name, start: start}}
+}
+
+// SetTag records the tags of the test case and sets them on the span.
+func (s *junitSpan) SetTag(key string, value interface{}) {
+	s.mutex.Lock()
+	switch key {
+	case constants.TestStatus:
+		s.c.status = fmt.Sprint(value)
+	case ext.ErrorType:
+		s.c.errorType = fmt.Sprint(value)
+	case ext.ErrorMsg:
+		s.c.errorMsg = fmt.Sprint(value)
+	case ext.ErrorStack:
+		s.c.errorStack = fmt.Sprint(value)
+	case constants.TestSkipReason:
+		s.c.skipReason = fmt.Sprint(value)
+	}
+	s.mutex.Unlock()
+	s.Span.SetTag(key, value)
+}
+
+// Finish records the test case with its duration and finishes the span.
+func (s *junitSpan) Finish(opts ...ddtrace.FinishOption) {
+	cfg := ddtrace.FinishConfig{}
+	for _, fn := range opts {
+		fn(&cfg)
+	}
+	end := cfg.FinishTime
+	if end.IsZero() {
+		end = time.Now()
+	}
+
+	s.mutex.Lock()
+	c := s.c
+	s.mutex.Unlock()
+	c.duration = end.Sub(c.start)
+
+	junitCasesMutex.Lock()
+	junitCases = append(junitCases, c)
+	junitCasesMutex.Unlock()
+
+	s.Span.Finish(opts...)
+}
+
+// writeJUnitReport writes the recorded test cases to the JUnit report, if enabled, with one test
+// suite per package. The name of the test module names the report written to a directory.
+func writeJUnitReport(moduleName string) {
+	path := junitReportPath()
+	if path == "" {
+		return
+	}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		name := strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(moduleName)
+		path = filepath.Join(path, name+".xml")
+	}
+
+	junitCasesMutex.Lock()
+	cases := junitCases
+	junitCases = nil
+	junitCasesMutex.Unlock()
+
+	f, err := os.Create(path)
+	if err == nil {
+		err = encodeJUnitReport(f, cases, tags)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		log.Printf("dd-sdk-go-testing: unable to write the JUnit report: %v", err)
+	}
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr,omitempty"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr,omitempty"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+// encodeJUnitReport writes the test cases as a JUnit XML report, grouped by suite in the order
+// the suites first finished a test, with the CI tags as the properties of every suite.
+func encodeJUnitReport(w io.Writer, cases []junitCase, ciTags map[string]string) error {
+	var properties []junitProperty
+	for k, v := range ciTags {
+		if v != "" {
+			properties = append(properties, junitProperty{Name: k, Value: v})
+		}
+	}
+	sort.Slice(properties, func(i, j int) bool { return properties[i].Name < properties[j].Name })
+
+	report := junitTestSuites{}
+	suites := map[string]int{}
+	starts := map[string]time.Time{}
+	ends := map[string]time.Time{}
+	for _, c := range cases {
+		idx, ok := suites[c.suite]
+		if !ok {
+			idx = len(report.Suites)
+			suites[c.suite] = idx
+			report.Suites = append(report.Suites, junitTestSuite{
+				Name:       c.suite,
+				Properties: properties,
+			})
+		}
+		suite := &report.Suites[idx]
+
+		tc := junitTestCase{Name: c.name, ClassName: c.suite, Time: junitSeconds(c.duration)}
+		switch c.status {
+		case constants.TestStatusFail:
+			text := c.errorStack
+			if text == "" {
+				text = c.errorMsg
+			}
+			tc.Failure = &junitFailure{Message: c.errorMsg, Type: c.errorType, Text: text}
+			suite.Failures++
+		case constants.TestStatusSkip:
+			tc.Skipped = &junitSkipped{Message: c.skipReason}
+			suite.Skipped++
+		}
+		suite.Tests++
+		suite.Cases = append(suite.Cases, tc)
+
+		if start, ok := starts[c.suite]; !ok || c.start.Before(start) {
+			starts[c.suite] = c.start
+		}
+		if end := c.start.Add(c.duration); end.After(ends[c.suite]) {
+			ends[c.suite] = end
+		}
+	}
+
+	// Subtests run during their parent test, so a suite lasts from its first test start to its
+	// last test end.
+	var total time.Duration
+	for i := range report.Suites {
+		suite := &report.Suites[i]
+		duration := ends[suite.Name].Sub(starts[suite.Name])
+		suite.Time = junitSeconds(duration)
+		suite.Timestamp = starts[suite.Name].UTC().Format("2006-01-02T15:04:05")
+		total += duration
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Skipped += suite.Skipped
+	}
+	report.Time = junitSeconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
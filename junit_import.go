@@ -0,0 +1,244 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package dd_sdk_go_testing
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/constants"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// junitFramework is the test framework of the tests imported from JUnit XML files, which may be
+// written by the test runners of any language.
+const junitFramework = "junit"
+
+// junitTimestampLayouts are the layouts of the timestamps of the test suites written by the
+// common JUnit reporters, which usually omit the time zone.
+var junitTimestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+}
+
+// junitInputSuites is the root element of a JUnit XML file holding several test suites.
+type junitInputSuites struct {
+	Name   string            `xml:"name,attr"`
+	Suites []junitInputSuite `xml:"testsuite"`
+}
+
+// junitInputSuite is a test suite of a JUnit XML file, which may be nested.
+type junitInputSuite struct {
+	Name      string            `xml:"name,attr"`
+	Timestamp string            `xml:"timestamp,attr"`
+	Suites    []junitInputSuite `xml:"testsuite"`
+	Cases     []junitInputCase  `xml:"testcase"`
+}
+
+// junitInputCase is a test case of a JUnit XML file.
+type junitInputCase struct {
+	Name      string            `xml:"name,attr"`
+	ClassName string            `xml:"classname,attr"`
+	Time      string            `xml:"time,attr"`
+	Failure   *junitInputResult `xml:"failure"`
+	Error     *junitInputResult `xml:"error"`
+	Skipped   *junitInputResult `xml:"skipped"`
+}
+
+// junitInputResult is the failure, error or skip of a test case.
+type junitInputResult struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// ImportJUnit reports the tests of JUnit XML files, such as the ones written by gotestsum,
+// go-junit-report or the test runners of other languages, as test spans tagged with the CI and
+// Git metadata of the environment. The tests of each file are reported in a test session and
+// module of their own, named after the file unless its root element is named, with a test suite
+// per class name. The files that can't be read are reported in the returned error, after the
+// tests of the other files are reported.
+func ImportJUnit(paths []string, opts ...tracer.StartOption) error {
+	stop := startExporter(opts...)
+	defer stop()
+
+	var failed []string
+	for _, path := range paths {
+		if err := importJUnitFile(path); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return errors.New(strings.Join(failed, "\n"))
+	}
+	return nil
+}
+
+// importJUnitFile reports the tests of a JUnit XML file.
+func importJUnitFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	name, suites, err := decodeJUnit(f)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	// Tests without timestamps end when the file was written.
+	end := time.Now()
+	if info, err := f.Stat(); err == nil {
+		end = info.ModTime()
+	}
+	reportJUnit(fmt.Sprintf("junit %s", path), name, suites, end)
+	return nil
+}
+
+// decodeJUnit returns the name and the test suites of a JUnit XML file, whose root element is
+// either testsuites or a single testsuite.
+func decodeJUnit(r io.Reader) (string, []junitInputSuite, error) {
+	dec := xml.NewDecoder(r)
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			return "", nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "testsuites":
+			var root junitInputSuites
+			err := dec.DecodeElement(&root, &start)
+			return root.Name, root.Suites, err
+		case "testsuite":
+			var suite junitInputSuite
+			err := dec.DecodeElement(&suite, &start)
+			return "", []junitInputSuite{suite}, err
+		default:
+			return "", nil, fmt.Errorf("unexpected root element %s", start.Name.Local)
+		}
+	}
+}
+
+// reportJUnit sends the spans of the test suites of a JUnit XML file in a session. The tests of a
+// suite run one after the other from its timestamp, or before end when it has none.
+func reportJUnit(command, moduleName string, suites []junitInputSuite, end time.Time) {
+	type timedCase struct {
+		junitInputCase
+		suite    string
+		start    time.Time
+		duration time.Duration
+	}
+
+	var cases []timedCase
+	var walk func(suites []junitInputSuite)
+	walk = func(suites []junitInputSuite) {
+		for _, suite := range suites {
+			var duration time.Duration
+			var suiteCases []timedCase
+			for _, c := range suite.Cases {
+				seconds, _ := strconv.ParseFloat(c.Time, 64)
+				d := time.Duration(seconds * float64(time.Second))
+				name := c.ClassName
+				if name == "" {
+					name = suite.Name
+				}
+				suiteCases = append(suiteCases, timedCase{junitInputCase: c, suite: name, duration: d})
+				duration += d
+			}
+
+			start, ok := parseJUnitTimestamp(suite.Timestamp)
+			if !ok {
+				start = end.Add(-duration)
+			}
+			for i := range suiteCases {
+				suiteCases[i].start = start
+				start = start.Add(suiteCases[i].duration)
+			}
+			cases = append(cases, suiteCases...)
+			walk(suite.Suites)
+		}
+	}
+	walk(suites)
+	if len(cases) == 0 {
+		return
+	}
+
+	sessionStart, sessionEnd := cases[0].start, cases[0].start
+	for _, c := range cases {
+		if c.start.Before(sessionStart) {
+			sessionStart = c.start
+		}
+		if e := c.start.Add(c.duration); e.After(sessionEnd) {
+			sessionEnd = e
+		}
+	}
+
+	s := newImportedSession(command, moduleName, junitFramework, sessionStart)
+	failed := false
+	for _, c := range cases {
+		test := externalTest{
+			suite:    c.suite,
+			name:     c.Name,
+			testType: constants.TestTypeTest,
+			start:    c.start,
+			end:      c.start.Add(c.duration),
+			status:   constants.TestStatusPass,
+		}
+		if result := c.Failure; result != nil || c.Error != nil {
+			if result == nil {
+				result = c.Error
+			}
+			test.status = constants.TestStatusFail
+			test.errorType = result.Type
+			test.errorMsg = result.Message
+			test.errorStack = strings.TrimSpace(result.Text)
+			if test.errorMsg == "" {
+				test.errorMsg = test.errorStack
+			}
+			failed = true
+		} else if c.Skipped != nil {
+			test.status = constants.TestStatusSkip
+			test.skipReason = c.Skipped.Message
+			if test.skipReason == "" {
+				test.skipReason = strings.TrimSpace(c.Skipped.Text)
+			}
+		}
+		s.reportTest(s.getSuite(test.suite, test.start), test, nil)
+	}
+
+	exitCode := 0
+	if failed {
+		exitCode = 1
+	}
+	s.closeAt(exitCode, sessionEnd)
+}
+
+// parseJUnitTimestamp parses the timestamp of a test suite, in UTC when it has no time zone.
+func parseJUnitTimestamp(value string) (time.Time, bool) {
+	for _, layout := range junitTimestampLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
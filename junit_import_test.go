@@ -0,0 +1,145 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package dd_sdk_go_testing
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/constants"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+)
+
+const testJUnitXML = `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="pipeline">
+	<testsuite name="example.com/a" tests="3" failures="1" timestamp="2021-06-01T10:00:00">
+		<testcase name="TestPass" classname="example.com/a" time="0.100"></testcase>
+		<testcase name="TestFail" classname="example.com/a" time="0.200">
+			<failure message="Failed" type="assertion">a_test.go:12: unexpected value</failure>
+		</testcase>
+		<testcase name="TestSkip" classname="example.com/a" time="0.000">
+			<skipped message="not supported"></skipped>
+		</testcase>
+	</testsuite>
+	<testsuite name="frontend">
+		<testcase name="renders" classname="App" time="1.5">
+			<error type="TypeError">TypeError: undefined is not a function</error>
+		</testcase>
+	</testsuite>
+</testsuites>
+`
+
+func TestReportJUnit(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	name, suites, err := decodeJUnit(strings.NewReader(testJUnitXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual("pipeline", name)
+	end := time.Date(2021, 6, 1, 11, 0, 0, 0, time.UTC)
+	reportJUnit("junit report.xml", name, suites, end)
+
+	tests := map[string]mocktracer.Span{}
+	var session mocktracer.Span
+	for _, s := range mt.FinishedSpans() {
+		switch s.Tag(ext.SpanType) {
+		case constants.SpanTypeTest:
+			tests[s.Tag(constants.TestName).(string)] = s
+		case constants.SpanTypeTestSession:
+			session = s
+		}
+	}
+	if len(tests) != 4 || session == nil {
+		t.Fatalf("unexpected spans: %d tests, session %v", len(tests), session)
+	}
+
+	pass, fail, skip, renders := tests["TestPass"], tests["TestFail"], tests["TestSkip"], tests["renders"]
+	assertEqual(constants.TestStatusPass, pass.Tag(constants.TestStatus).(string))
+	assertEqual("2021-06-01T10:00:00Z", pass.StartTime().UTC().Format(time.RFC3339))
+	assertEqual("example.com/a", fail.Tag(constants.TestSuite).(string))
+	assertEqual(constants.TestStatusFail, fail.Tag(constants.TestStatus).(string))
+	assertEqual("assertion", fail.Tag(ext.ErrorType).(string))
+	assertEqual("Failed", fail.Tag(ext.ErrorMsg).(string))
+	assertEqual("a_test.go:12: unexpected value", fail.Tag(ext.ErrorStack).(string))
+	assertEqual("100ms", fail.StartTime().Sub(pass.StartTime()).String())
+	assertEqual("200ms", fail.FinishTime().Sub(fail.StartTime()).String())
+	assertEqual(constants.TestStatusSkip, skip.Tag(constants.TestStatus).(string))
+	assertEqual("not supported", skip.Tag(constants.TestSkipReason).(string))
+
+	// Tests without timestamps end when the file was written.
+	assertEqual("App", renders.Tag(constants.TestSuite).(string))
+	assertEqual(constants.TestStatusFail, renders.Tag(constants.TestStatus).(string))
+	assertEqual("TypeError: undefined is not a function", renders.Tag(ext.ErrorMsg).(string))
+	if !renders.FinishTime().Equal(end) {
+		t.Fatalf("unexpected end: %v", renders.FinishTime())
+	}
+
+	assertEqual("junit report.xml", session.Tag(constants.TestCommand).(string))
+	assertEqual(constants.TestStatusFail, session.Tag(constants.TestStatus).(string))
+
+	// The tests may be run by any test runner, not by the runtime of the SDK.
+	for _, span := range []mocktracer.Span{pass, session} {
+		assertEqual(junitFramework, span.Tag(constants.TestFramework).(string))
+		if span.Tag(constants.RuntimeName) != nil || span.Tag(constants.RuntimeVersion) != nil {
+			t.Fatal("unexpected runtime tags")
+		}
+	}
+}
+
+func TestImportJUnit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "junit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	valid := filepath.Join(dir, "valid.xml")
+	invalid := filepath.Join(dir, "invalid.xml")
+	if err := ioutil.WriteFile(valid, []byte(testJUnitXML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(invalid, []byte("<report></report>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := NewRecorder()
+	defer useExporter(rec)()
+	err = ImportJUnit([]string{filepath.Join(dir, "missing.xml"), invalid, valid})
+	if err == nil || !strings.Contains(err.Error(), "missing.xml") || !strings.Contains(err.Error(), "invalid.xml") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := 0
+	for _, span := range rec.FinishedSpans() {
+		if span.Tag(ext.SpanType) == constants.SpanTypeTest {
+			tests++
+		}
+	}
+	if tests != 4 {
+		t.Fatalf("the tests of the valid file were not reported: %d tests", tests)
+	}
+}
+
+func TestDecodeJUnitSuite(t *testing.T) {
+	name, suites, err := decodeJUnit(strings.NewReader(`<testsuite name="a"><testcase name="TestA"/></testsuite>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "" || len(suites) != 1 || len(suites[0].Cases) != 1 {
+		t.Fatalf("unexpected suites: %q %v", name, suites)
+	}
+
+	if _, _, err := decodeJUnit(strings.NewReader(`<report></report>`)); err == nil {
+		t.Fatal("expected an error")
+	}
+}
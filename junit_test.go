@@ -0,0 +1,130 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package dd_sdk_go_testing
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/constants"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+func TestJUnitReport(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	dir, err := ioutil.TempDir("", "junit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	SetJUnitReport(dir)
+	defer SetJUnitReport("")
+
+	t.Run("pass", func(t *testing.T) {
+		ctx, finish := StartTest(t)
+		defer finish()
+
+		// Tags set through the context are recorded as well.
+		if span, _ := tracer.SpanFromContext(ctx); !isJUnitSpan(span) {
+			t.Fatal("unexpected span in the context")
+		}
+	})
+	t.Run("skip", func(t *testing.T) {
+		tt := WrapT(t)
+		_, finish := StartTest(tt)
+		defer finish()
+		tt.Skip("not supported")
+	})
+	writeJUnitReport("example.com/module")
+
+	if len(mt.FinishedSpans()) != 2 {
+		t.Fatalf("unexpected spans: %d", len(mt.FinishedSpans()))
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "example.com_module.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report junitTestSuites
+	if err := xml.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Tests != 2 || report.Skipped != 1 || len(report.Suites) != 1 {
+		t.Fatalf("unexpected report:\n%s", data)
+	}
+
+	suite := report.Suites[0]
+	assertEqual("github.com/DataDog/dd-sdk-go-testing", suite.Name)
+	assertEqual("TestJUnitReport/pass", suite.Cases[0].Name)
+	assertEqual("TestJUnitReport/skip", suite.Cases[1].Name)
+	if suite.Cases[0].Skipped != nil || suite.Cases[1].Skipped == nil {
+		t.Fatalf("unexpected test cases:\n%s", data)
+	}
+	assertEqual("not supported", suite.Cases[1].Skipped.Message)
+
+	properties := map[string]string{}
+	for _, p := range suite.Properties {
+		properties[p.Name] = p.Value
+	}
+	sha, _ := getFromCITags(constants.GitCommitSHA)
+	assertEqual(sha, properties[constants.GitCommitSHA])
+}
+
+func TestJUnitReportPath(t *testing.T) {
+	os.Setenv(junitReportEnv, "env.xml")
+	defer os.Unsetenv(junitReportEnv)
+	assertEqual("env.xml", junitReportPath())
+
+	SetJUnitReport("option.xml")
+	assertEqual("option.xml", junitReportPath())
+	SetJUnitReport("")
+	assertEqual("env.xml", junitReportPath())
+}
+
+func TestEncodeJUnitFailure(t *testing.T) {
+	f, err := ioutil.TempFile("", "junit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	cases := []junitCase{{
+		suite:      "example.com/a",
+		name:       "TestFail",
+		status:     constants.TestStatusFail,
+		errorType:  "Fatal",
+		errorMsg:   "boom",
+		errorStack: "main.TestFail\n\ta_test.go:12\n",
+	}}
+	if err := encodeJUnitReport(f, cases, nil); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	data, _ := ioutil.ReadFile(f.Name())
+	var report junitTestSuites
+	if err := xml.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	failure := report.Suites[0].Cases[0].Failure
+	if report.Failures != 1 || failure == nil {
+		t.Fatalf("unexpected report:\n%s", data)
+	}
+	assertEqual("boom", failure.Message)
+	assertEqual("Fatal", failure.Type)
+	assertEqual("main.TestFail\n\ta_test.go:12\n", failure.Text)
+}
+
+func isJUnitSpan(span interface{}) bool {
+	_, ok := span.(*junitSpan)
+	return ok
+}
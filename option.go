@@ -6,11 +6,16 @@
 package dd_sdk_go_testing
 
 import (
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
 	"runtime"
 	"sync"
 	"testing"
 
 	"github.com/DataDog/dd-sdk-go-testing/internal/constants"
+	"github.com/DataDog/dd-sdk-go-testing/internal/settings"
 	"github.com/DataDog/dd-sdk-go-testing/internal/utils"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
@@ -21,13 +26,22 @@ var (
 	// tags contains information detected from CI/CD environment variables.
 	tags     map[string]string
 	tagsOnce sync.Once
+
+	settingsOnce sync.Once
+
+	// codeOwners contains the CODEOWNERS rules of the repository at codeOwnersRoot, nil when
+	// the repository has no CODEOWNERS file.
+	codeOwners     *utils.CodeOwners
+	codeOwnersRoot string
+	codeOwnersOnce sync.Once
 )
 
 type config struct {
 	skip             int
 	spanOpts         []ddtrace.StartSpanOption
 	finishOpts       []ddtrace.FinishOption
-	originalTestFunc func(*testing.T)
+	originalTestFunc interface{}
+	testName         string
 }
 
 // Option represents an option that can be passed to NewServeMux or WrapHandler.
@@ -48,6 +62,9 @@ func defaults(cfg *config) {
 		cfg.spanOpts = append(cfg.spanOpts, tracer.Tag(k, v))
 	})
 
+	// Ensure test settings
+	ensureSettings()
+
 	cfg.finishOpts = []ddtrace.FinishOption{}
 }
 
@@ -80,6 +97,10 @@ func ensureCITagsLocked() {
 	}
 
 	if localTags[constants.GitCommitSHA] == gitData.CommitSha {
+		// The CI providers set an empty tag when not building a tag.
+		if localTags[constants.GitTag] == "" && gitData.Tag != "" {
+			localTags[constants.GitTag] = gitData.Tag
+		}
 		if _, ok := localTags[constants.GitCommitAuthorDate]; !ok {
 			localTags[constants.GitCommitAuthorDate] = gitData.AuthorDate.String()
 		}
@@ -107,6 +128,25 @@ func ensureCITagsLocked() {
 	tags = localTags
 }
 
+func ensureSettings() {
+	settingsOnce.Do(ensureSettingsLocked)
+}
+
+func ensureSettingsLocked() {
+	ensureCITags()
+	req := settings.Request{
+		Env:            os.Getenv("DD_ENV"),
+		RepositoryURL:  tags[constants.GitRepositoryURL],
+		Sha:            tags[constants.GitCommitSHA],
+		Configurations: map[string]string{},
+	}
+	req.Service, _ = getServiceName()
+	for _, key := range []string{constants.OSPlatform, constants.OSVersion, constants.OSArchitecture, constants.RuntimeName, constants.RuntimeVersion} {
+		req.Configurations[key] = tags[key]
+	}
+	settings.Load(req)
+}
+
 func getFromCITags(key string) (string, bool) {
 	if value, ok := tags[key]; ok {
 		return value, ok
@@ -146,7 +186,44 @@ func WithIncrementSkipFrame() Option {
 
 // WithOriginalTestFunc sets the original test function
 func WithOriginalTestFunc(f func(*testing.T)) Option {
+	return WithOriginalFunc(f)
+}
+
+// WithOriginalFunc sets the original function of a test of any kind, such as an example or a
+// benchmark. It is used instead of the caller to detect the suite and source of the test.
+func WithOriginalFunc(f interface{}) Option {
+	return func(cfg *config) {
+		if f != nil && !reflect.ValueOf(f).IsNil() {
+			cfg.originalTestFunc = f
+		}
+	}
+}
+
+// WithTestName overrides the test name detected from the TB interface.
+func WithTestName(name string) Option {
 	return func(cfg *config) {
-		cfg.originalTestFunc = f
+		cfg.testName = name
+	}
+}
+
+// getCodeOwners returns the owners of a source file according to the CODEOWNERS file of the
+// repository.
+func getCodeOwners(file string) []string {
+	codeOwnersOnce.Do(func() {
+		gitData, _ := utils.LocalGetGitData()
+		if gitData.SourceRoot == "" {
+			return
+		}
+		owners, err := utils.LoadCodeOwners(gitData.SourceRoot)
+		if err != nil {
+			log.Printf("dd-sdk-go-testing: unable to load the CODEOWNERS file: %v", err)
+			return
+		}
+		codeOwners, codeOwnersRoot = owners, gitData.SourceRoot
+	})
+	path := utils.RelativeSourcePath(codeOwnersRoot, file)
+	if codeOwners == nil || filepath.IsAbs(path) {
+		return nil
 	}
+	return codeOwners.Match(path)
 }
@@ -0,0 +1,315 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package dd_sdk_go_testing
+
+import (
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/constants"
+	"github.com/DataDog/dd-sdk-go-testing/internal/coverage"
+	"github.com/DataDog/dd-sdk-go-testing/internal/settings"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+const (
+	sessionOperationName = "test_session"
+	moduleOperationName  = "test_module"
+	suiteOperationName   = "test_suite"
+)
+
+var (
+	// currentSession is the session opened by Run, nil when tests are run without it.
+	currentSession      *session
+	currentSessionMutex sync.RWMutex
+)
+
+// session is the test session opened by Run. It owns the module span of the test binary
+// and one suite span for each package seen by StartTestWithContext.
+type session struct {
+	mutex      sync.Mutex
+	span       ddtrace.Span
+	module     ddtrace.Span
+	moduleName string
+	framework  string
+	// imported is set for the tests of other test runners, whose spans are not tagged with the
+	// runtime of the SDK.
+	imported   bool
+	suites     map[string]*testSuite
+	status     status
+	itrSkipped int
+	closed     bool
+}
+
+// testSuite groups the tests of a single package.
+type testSuite struct {
+	span    ddtrace.Span
+	status  status
+	lastEnd time.Time
+}
+
+// status rolls up the outcome of the children of a session, module or suite span.
+type status struct {
+	passed  int
+	failed  int
+	skipped int
+}
+
+func (s *status) add(value string) {
+	switch value {
+	case constants.TestStatusFail:
+		s.failed++
+	case constants.TestStatusSkip:
+		s.skipped++
+	default:
+		s.passed++
+	}
+}
+
+// String returns fail if any child failed, pass if any child passed and skip otherwise.
+func (s status) String() string {
+	if s.failed > 0 {
+		return constants.TestStatusFail
+	} else if s.passed > 0 {
+		return constants.TestStatusPass
+	}
+	return constants.TestStatusSkip
+}
+
+// startSession opens the session and module spans for the running test binary and sets it
+// as the current session.
+func startSession() *session {
+	s := newSession(getTestCommand(), getModuleName(), time.Now())
+
+	currentSessionMutex.Lock()
+	currentSession = s
+	currentSessionMutex.Unlock()
+	return s
+}
+
+// newSession opens the session and module spans of a test command running the tests of a
+// module with the testing package, started at the given time.
+func newSession(command, moduleName string, start time.Time) *session {
+	s := &session{
+		moduleName: moduleName,
+		framework:  testFramework,
+		suites:     map[string]*testSuite{},
+	}
+	s.open(command, start)
+	return s
+}
+
+// newImportedSession opens the session and module spans of the tests of a module run by another
+// test runner of the given framework, started at the given time.
+func newImportedSession(command, moduleName, framework string, start time.Time) *session {
+	s := &session{
+		moduleName: moduleName,
+		framework:  framework,
+		imported:   true,
+		suites:     map[string]*testSuite{},
+	}
+	s.open(command, start)
+	return s
+}
+
+// open starts the session and module spans.
+func (s *session) open(command string, start time.Time) {
+	s.span = getExporter().StartSpan(sessionOperationName, append(s.eventSpanOptions(constants.SpanTypeTestSession),
+		tracer.StartTime(start),
+		tracer.ResourceName(command),
+		tracer.Tag(constants.TestCommand, command),
+	)...)
+	s.module = getExporter().StartSpan(moduleOperationName, append(s.eventSpanOptions(constants.SpanTypeTestModule),
+		tracer.StartTime(start),
+		tracer.ChildOf(s.span.Context()),
+		tracer.ResourceName(s.moduleName),
+		tracer.Tag(constants.TestCommand, command),
+		tracer.Tag(constants.TestModule, s.moduleName),
+		tracer.Tag(constants.TestSessionID, spanID(s.span)),
+	)...)
+
+	if settings.EarlyFlakeDetectionEnabled() {
+		s.span.SetTag(constants.TestEarlyFlakeDetectionEnabled, "true")
+	}
+}
+
+// getSession returns the current session or nil if Run was not used.
+func getSession() *session {
+	currentSessionMutex.RLock()
+	defer currentSessionMutex.RUnlock()
+	return currentSession
+}
+
+// closeSession closes the current session, if any, with the given exit code.
+func closeSession(exitCode int) {
+	if s := getSession(); s != nil {
+		s.close(exitCode)
+	}
+}
+
+// getSuite returns the suite for the given package, starting its span at the given time on
+// first use.
+func (s *session) getSuite(name string, start time.Time) *testSuite {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if st, ok := s.suites[name]; ok {
+		return st
+	}
+
+	st := &testSuite{
+		span: getExporter().StartSpan(suiteOperationName, append(s.eventSpanOptions(constants.SpanTypeTestSuite),
+			tracer.StartTime(start),
+			tracer.ChildOf(s.module.Context()),
+			tracer.ResourceName(name),
+			tracer.Tag(constants.TestSuite, name),
+			tracer.Tag(constants.TestModule, s.moduleName),
+			tracer.Tag(constants.TestSessionID, spanID(s.span)),
+			tracer.Tag(constants.TestModuleID, spanID(s.module)),
+		)...),
+	}
+	s.suites[name] = st
+	return st
+}
+
+// testSpanOptions returns the tags linking a test span to its session, module and suite.
+func (s *session) testSpanOptions(st *testSuite) []ddtrace.StartSpanOption {
+	return []ddtrace.StartSpanOption{
+		tracer.Tag(constants.TestModule, s.moduleName),
+		tracer.Tag(constants.TestSessionID, spanID(s.span)),
+		tracer.Tag(constants.TestModuleID, spanID(s.module)),
+		tracer.Tag(constants.TestSuiteID, spanID(st.span)),
+	}
+}
+
+// finishTest rolls the status of a finished test up to its suite and the session.
+func (s *session) finishTest(st *testSuite, testStatus string, end time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return
+	}
+	st.status.add(testStatus)
+	s.status.add(testStatus)
+	if end.After(st.lastEnd) {
+		st.lastEnd = end
+	}
+}
+
+// skipTest records a test skipped by the intelligent test runner.
+func (s *session) skipTest(st *testSuite, end time.Time) {
+	s.finishTest(st, constants.TestStatusSkip, end)
+
+	s.mutex.Lock()
+	s.itrSkipped++
+	s.mutex.Unlock()
+}
+
+// close finishes every suite span, then the module and session spans. A non-zero exit code
+// marks the module and session as failed even if no test failed.
+func (s *session) close(exitCode int) {
+	s.closeAt(exitCode, time.Now())
+}
+
+// closeAt closes the session at the given time.
+func (s *session) closeAt(exitCode int, end time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+
+	for _, st := range s.suites {
+		var opts []ddtrace.FinishOption
+		if !st.lastEnd.IsZero() {
+			opts = append(opts, tracer.FinishTime(st.lastEnd))
+		}
+		finishEventSpan(st.span, st.status.String(), opts...)
+	}
+
+	sessionStatus := s.status.String()
+	if exitCode != 0 {
+		sessionStatus = constants.TestStatusFail
+	}
+	if settings.TestSkippingEnabled() {
+		for _, span := range []ddtrace.Span{s.module, s.span} {
+			span.SetTag(constants.TestITRSkippingEnabled, "true")
+			span.SetTag(constants.TestITRSkippingCount, s.itrSkipped)
+			span.SetTag(constants.ITRTestsSkipped, strconv.FormatBool(s.itrSkipped > 0))
+		}
+	}
+	if coverage.Enabled() {
+		for _, span := range []ddtrace.Span{s.module, s.span} {
+			span.SetTag(constants.TestCodeCoverageEnabled, "true")
+			span.SetTag(constants.TestCodeCoverageLinesPercentage, coverage.Total())
+		}
+	}
+	finishEventSpan(s.module, sessionStatus, tracer.FinishTime(end))
+	finishEventSpan(s.span, sessionStatus, tracer.FinishTime(end))
+
+	currentSessionMutex.Lock()
+	if currentSession == s {
+		currentSession = nil
+	}
+	currentSessionMutex.Unlock()
+}
+
+// eventSpanOptions returns the options shared by the session, module, suite and imported test
+// spans of the session.
+func (s *session) eventSpanOptions(spanType string) []ddtrace.StartSpanOption {
+	opts := []ddtrace.StartSpanOption{
+		tracer.SpanType(spanType),
+		tracer.Tag(constants.SpanKind, spanKind),
+		tracer.Tag(constants.TestFramework, s.framework),
+		tracer.Tag(constants.Origin, constants.CIAppTestOrigin),
+		tracer.Tag(ext.ManualKeep, true),
+	}
+
+	ensureCITags()
+	forEachCITags(func(k, v string) {
+		if s.imported && (k == constants.RuntimeName || k == constants.RuntimeVersion) {
+			return
+		}
+		opts = append(opts, tracer.Tag(k, v))
+	})
+
+	return opts
+}
+
+func finishEventSpan(span ddtrace.Span, eventStatus string, opts ...ddtrace.FinishOption) {
+	span.SetTag(constants.TestStatus, eventStatus)
+	span.SetTag(ext.Error, eventStatus == constants.TestStatusFail)
+	span.Finish(opts...)
+}
+
+func spanID(span ddtrace.Span) string {
+	return strconv.FormatUint(span.Context().SpanID(), 10)
+}
+
+// getModuleName returns the import path of the package under test, taken from the build
+// information of the test binary (eg: github.com/DataDog/dd-sdk-go-testing.test).
+func getModuleName() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Path != "" {
+		return strings.TrimSuffix(info.Path, ".test")
+	}
+	return strings.TrimSuffix(filepath.Base(os.Args[0]), ".test")
+}
+
+// getTestCommand returns the command line of the test binary without its temporary directory.
+func getTestCommand() string {
+	args := append([]string{filepath.Base(os.Args[0])}, os.Args[1:]...)
+	return strings.Join(args, " ")
+}
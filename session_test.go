@@ -0,0 +1,100 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package dd_sdk_go_testing
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/constants"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+)
+
+// withTestSession replaces the session opened by TestMain with a new one for the duration of fn.
+func withTestSession(fn func(s *session)) {
+	previous := getSession()
+	defer func() {
+		currentSessionMutex.Lock()
+		currentSession = previous
+		currentSessionMutex.Unlock()
+	}()
+	fn(startSession())
+}
+
+func TestSession(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	withTestSession(func(s *session) {
+		t.Run("pass", func(t *testing.T) {
+			_, finish := StartTest(t)
+			defer finish()
+		})
+
+		t.Run("skip", func(t *testing.T) {
+			_, finish := StartTest(t)
+			defer finish()
+			t.Skip("good reason")
+		})
+
+		s.close(0)
+	})
+
+	spans := mt.FinishedSpans()
+	if len(spans) != 5 {
+		t.FailNow()
+	}
+
+	const suiteName string = "github.com/DataDog/dd-sdk-go-testing"
+
+	sessionSpan, moduleSpan, suiteSpan := spans[4], spans[3], spans[2]
+	assertEqual(constants.SpanTypeTestSession, sessionSpan.Tag(ext.SpanType).(string))
+	assertEqual(constants.TestStatusPass, sessionSpan.Tag(constants.TestStatus).(string))
+	assertNotEmpty(sessionSpan.Tag(constants.TestCommand).(string))
+
+	assertEqual(constants.SpanTypeTestModule, moduleSpan.Tag(ext.SpanType).(string))
+	assertEqual(constants.TestStatusPass, moduleSpan.Tag(constants.TestStatus).(string))
+	assertEqual(suiteName, moduleSpan.Tag(constants.TestModule).(string))
+	assertEqual(fmt.Sprint(sessionSpan.SpanID()), moduleSpan.Tag(constants.TestSessionID).(string))
+
+	assertEqual(constants.SpanTypeTestSuite, suiteSpan.Tag(ext.SpanType).(string))
+	assertEqual(constants.TestStatusPass, suiteSpan.Tag(constants.TestStatus).(string))
+	assertEqual(suiteName, suiteSpan.Tag(constants.TestSuite).(string))
+	assertEqual(fmt.Sprint(moduleSpan.SpanID()), suiteSpan.Tag(constants.TestModuleID).(string))
+	if suiteSpan.FinishTime().Before(spans[1].FinishTime()) || suiteSpan.FinishTime().After(moduleSpan.FinishTime()) {
+		t.FailNow()
+	}
+
+	for _, s := range spans[:2] {
+		assertEqual(suiteName, s.Tag(constants.TestModule).(string))
+		assertEqual(fmt.Sprint(sessionSpan.SpanID()), s.Tag(constants.TestSessionID).(string))
+		assertEqual(fmt.Sprint(moduleSpan.SpanID()), s.Tag(constants.TestModuleID).(string))
+		assertEqual(fmt.Sprint(suiteSpan.SpanID()), s.Tag(constants.TestSuiteID).(string))
+	}
+}
+
+func TestSessionStatus(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	withTestSession(func(s *session) {
+		s.finishTest(s.getSuite("pkg/a", time.Now()), constants.TestStatusPass, time.Now())
+		s.finishTest(s.getSuite("pkg/a", time.Now()), constants.TestStatusFail, time.Now())
+		s.finishTest(s.getSuite("pkg/b", time.Now()), constants.TestStatusSkip, time.Now())
+		s.close(1)
+	})
+
+	status := map[string]string{}
+	for _, s := range mt.FinishedSpans() {
+		status[s.Tag(ext.ResourceName).(string)] = s.Tag(constants.TestStatus).(string)
+	}
+	assertEqual(constants.TestStatusFail, status["pkg/a"])
+	assertEqual(constants.TestStatusSkip, status["pkg/b"])
+	assertEqual(constants.TestStatusFail, status[getModuleName()])
+	assertEqual(constants.TestStatusFail, status[getTestCommand()])
+}
@@ -0,0 +1,147 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package dd_sdk_go_testing
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// T wraps a *testing.T to record what the test reports through it: the skip reason and the
+// failure messages with their location. Pass it to StartTest instead of the *testing.T to add
+// that information to the test span:
+//
+//	func TestExample(t *testing.T) {
+//		tt := ddtesting.WrapT(t)
+//		_, finish := ddtesting.StartTest(tt)
+//		defer finish()
+//
+//		if runtime.GOOS == "plan9" {
+//			tt.Skip("not supported on this platform")
+//		}
+//		if err := run(); err != nil {
+//			tt.Fatalf("run: %v", err)
+//		}
+//	}
+type T struct {
+	*testing.T
+
+	mutex      sync.Mutex
+	skipReason string
+	failures   []failure
+}
+
+// failure is a message reported through Error, Errorf, Fatal or Fatalf.
+type failure struct {
+	kind     string
+	message  string
+	function string
+	file     string
+	line     int
+}
+
+var _ TB = (*T)(nil)
+
+// WrapT returns a T wrapping t.
+func WrapT(t *testing.T) *T {
+	return &T{T: t}
+}
+
+// Skip records the skip reason and calls t.Skip.
+func (t *T) Skip(args ...interface{}) {
+	t.T.Helper()
+	t.setSkipReason(strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
+	t.T.Skip(args...)
+}
+
+// Skipf records the skip reason and calls t.Skipf.
+func (t *T) Skipf(format string, args ...interface{}) {
+	t.T.Helper()
+	t.setSkipReason(fmt.Sprintf(format, args...))
+	t.T.Skipf(format, args...)
+}
+
+// Error records the failure message and calls t.Error.
+func (t *T) Error(args ...interface{}) {
+	t.T.Helper()
+	t.addFailure("Error", fmt.Sprintln(args...))
+	t.T.Error(args...)
+}
+
+// Errorf records the failure message and calls t.Errorf.
+func (t *T) Errorf(format string, args ...interface{}) {
+	t.T.Helper()
+	t.addFailure("Error", fmt.Sprintf(format, args...))
+	t.T.Errorf(format, args...)
+}
+
+// Fatal records the failure message and calls t.Fatal.
+func (t *T) Fatal(args ...interface{}) {
+	t.T.Helper()
+	t.addFailure("Fatal", fmt.Sprintln(args...))
+	t.T.Fatal(args...)
+}
+
+// Fatalf records the failure message and calls t.Fatalf.
+func (t *T) Fatalf(format string, args ...interface{}) {
+	t.T.Helper()
+	t.addFailure("Fatal", fmt.Sprintf(format, args...))
+	t.T.Fatalf(format, args...)
+}
+
+// addFailure records a failure message along with the location of the call to the exported
+// method calling it.
+func (t *T) addFailure(kind, message string) {
+	f := failure{kind: kind, message: strings.TrimSuffix(message, "\n")}
+	if pc, file, line, ok := runtime.Caller(2); ok {
+		f.file, f.line = file, line
+		if fn := runtime.FuncForPC(pc); fn != nil {
+			f.function = fn.Name()
+		}
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.failures = append(t.failures, f)
+}
+
+// getFailureInfo returns the type, message and stack of the reported failures: the type of the
+// last failure, the messages one per line, and the location of each message.
+func (t *T) getFailureInfo() (errType string, message string, stack string, ok bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if len(t.failures) == 0 {
+		return "", "", "", false
+	}
+
+	messages := new(bytes.Buffer)
+	locations := new(bytes.Buffer)
+	for i, f := range t.failures {
+		if i > 0 {
+			messages.WriteByte('\n')
+		}
+		messages.WriteString(f.message)
+		fmt.Fprintf(locations, "%s\n\t%s:%d\n", f.function, f.file, f.line)
+	}
+	return t.failures[len(t.failures)-1].kind, messages.String(), locations.String(), true
+}
+
+func (t *T) setSkipReason(reason string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.skipReason = reason
+}
+
+// getSkipReason returns the reason given to Skip or Skipf, if any.
+func (t *T) getSkipReason() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.skipReason
+}
@@ -0,0 +1,117 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package dd_sdk_go_testing
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/constants"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+)
+
+func TestSkipReason(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	t.Run("skip", func(t *testing.T) {
+		tt := WrapT(t)
+		_, finish := StartTest(tt)
+		defer finish()
+		tt.Skip("good", "reason")
+	})
+
+	t.Run("skipf", func(t *testing.T) {
+		tt := WrapT(t)
+		_, finish := StartTest(tt)
+		defer finish()
+		tt.Skipf("issue #%d", 42)
+	})
+
+	spans := mt.FinishedSpans()
+	if len(spans) != 2 {
+		t.FailNow()
+	}
+
+	assertEqual(constants.TestStatusSkip, spans[0].Tag(constants.TestStatus).(string))
+	assertEqual("good reason", spans[0].Tag(constants.TestSkipReason).(string))
+	assertEqual(constants.TestTypeTest, spans[0].Tag(constants.TestType).(string))
+	assertEqual(constants.TestStatusSkip, spans[1].Tag(constants.TestStatus).(string))
+	assertEqual("issue #42", spans[1].Tag(constants.TestSkipReason).(string))
+}
+
+// TestFailureMessages runs the failing tests in a child process, which prints the error tags
+// of their spans, so that the failures don't fail this test.
+func TestFailureMessages(t *testing.T) {
+	if os.Getenv("DD_SDK_TESTING_FAILURES") == "1" {
+		runFailingTests(t)
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFailureMessages$")
+	cmd.Env = append(os.Environ(), "DD_SDK_TESTING_FAILURES=1")
+	out, _ := cmd.Output()
+
+	var tags []map[string]string
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(line, "{") {
+			var spanTags map[string]string
+			if err := json.Unmarshal([]byte(line), &spanTags); err != nil {
+				t.Fatal(err)
+			}
+			tags = append(tags, spanTags)
+		}
+	}
+	if len(tags) != 2 {
+		t.Fatalf("unexpected child output: %s", out)
+	}
+
+	assertEqual(constants.TestStatusFail, tags[0][constants.TestStatus])
+	assertEqual("Fatal", tags[0][ext.ErrorType])
+	assertEqual("first error\nsecond error 2\nfatal error", tags[0][ext.ErrorMsg])
+	if strings.Count(tags[0][ext.ErrorStack], "t_test.go:") != 3 {
+		t.Fatalf("unexpected error stack: %s", tags[0][ext.ErrorStack])
+	}
+
+	assertEqual(constants.TestStatusPass, tags[1][constants.TestStatus])
+	assertEqual("", tags[1][ext.ErrorMsg])
+}
+
+func runFailingTests(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	t.Run("fail", func(t *testing.T) {
+		tt := WrapT(t)
+		_, finish := StartTest(tt)
+		defer finish()
+		tt.Error("first", "error")
+		tt.Errorf("second error %d", 2)
+		tt.Fatal("fatal error")
+	})
+
+	t.Run("pass", func(t *testing.T) {
+		tt := WrapT(t)
+		_, finish := StartTest(tt)
+		defer finish()
+	})
+
+	for _, s := range mt.FinishedSpans() {
+		spanTags := map[string]string{}
+		for _, key := range []string{constants.TestStatus, ext.ErrorType, ext.ErrorMsg, ext.ErrorStack} {
+			if value, ok := s.Tag(key).(string); ok {
+				spanTags[key] = value
+			}
+		}
+		line, _ := json.Marshal(spanTags)
+		fmt.Println(string(line))
+	}
+}
@@ -0,0 +1,252 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package dd_sdk_go_testing
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/constants"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// testEvent is an event written by go test -json, as documented by go doc test2json.
+type testEvent struct {
+	Time    time.Time
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+	Output  string
+}
+
+// logLocationRegex matches the location of the line logging an entry of the output of a test.
+var logLocationRegex = regexp.MustCompile(`^[^\s:]+\.go:\d+: `)
+
+// jsonPackage collects the tests of a package until the event ending its test run, which tells
+// whether the results were replayed from the cache.
+type jsonPackage struct {
+	name   string
+	start  time.Time
+	cached bool
+	tests  map[string]*jsonTest
+	order  []*jsonTest
+}
+
+// jsonTest is a test run by a package, with the output it reported.
+type jsonTest struct {
+	name   string
+	start  time.Time
+	end    time.Time
+	status string
+	output []string
+}
+
+// RunJSON reads the events written by go test -json from r and copies them to w. The tests of
+// each package are reported as test spans once the package ends, in a test session and module
+// of their own, as when the test binary of the package is run with Run. The tests of packages
+// replayed from the go test cache are tagged as such.
+//
+// It returns 1 if a package failed, as go test does, 0 otherwise.
+func RunJSON(r io.Reader, w io.Writer, opts ...tracer.StartOption) (int, error) {
+	stop := startExporter(opts...)
+	defer stop()
+
+	exitCode, err := convertTestJSON(r, w)
+	writeJUnitReport("go-test-json")
+	return exitCode, err
+}
+
+// convertTestJSON copies the events read from r to w, and reports the tests of the packages.
+func convertTestJSON(r io.Reader, w io.Writer) (int, error) {
+	exitCode := 0
+	packages := map[string]*jsonPackage{}
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if _, werr := w.Write(line); werr != nil {
+				return exitCode, werr
+			}
+
+			var event testEvent
+			if json.Unmarshal(line, &event) == nil && event.Package != "" {
+				if status, ok := handleTestEvent(packages, event); ok && status == constants.TestStatusFail {
+					exitCode = 1
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return exitCode, err
+		}
+	}
+
+	// Report the packages interrupted before their end.
+	for _, p := range packages {
+		p.report(constants.TestStatusFail, time.Now())
+		exitCode = 1
+	}
+	return exitCode, nil
+}
+
+// handleTestEvent records an event of a package, and reports the package when the event ends its
+// test run, returning its status.
+func handleTestEvent(packages map[string]*jsonPackage, event testEvent) (string, bool) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	p, ok := packages[event.Package]
+	if !ok {
+		p = &jsonPackage{name: event.Package, start: event.Time, tests: map[string]*jsonTest{}}
+		packages[event.Package] = p
+	}
+
+	if event.Test == "" {
+		switch event.Action {
+		case "output":
+			if strings.Contains(event.Output, "(cached)") {
+				p.cached = true
+			}
+		case "pass", "fail", "skip":
+			delete(packages, event.Package)
+			p.report(event.Action, event.Time)
+			return event.Action, true
+		}
+		return "", false
+	}
+
+	test, ok := p.tests[event.Test]
+	if !ok {
+		test = &jsonTest{name: event.Test, start: event.Time}
+		p.tests[event.Test] = test
+		p.order = append(p.order, test)
+	}
+	switch event.Action {
+	case "output":
+		test.output = append(test.output, event.Output)
+	case "pass", "fail", "skip":
+		test.status = event.Action
+		test.end = event.Time
+	}
+	return "", false
+}
+
+// report sends the spans of the tests of the package, in a session ended with the package.
+func (p *jsonPackage) report(status string, end time.Time) {
+	if len(p.order) == 0 {
+		return
+	}
+
+	command := fmt.Sprintf("go test -json %s", p.name)
+	s := newSession(command, p.name, p.start)
+	st := s.getSuite(p.name, p.start)
+	if p.cached {
+		for _, span := range []ddtrace.Span{s.span, s.module, st.span} {
+			span.SetTag(constants.TestIsCached, "true")
+		}
+	}
+
+	spans := map[string]ddtrace.Span{}
+	for _, test := range p.order {
+		if test.status == "" {
+			// The package ended during the test.
+			test.status, test.end = constants.TestStatusFail, end
+		}
+
+		var parent ddtrace.Span
+		if i := strings.LastIndex(test.name, "/"); i > 0 {
+			parent = spans[test.name[:i]]
+		}
+		spans[test.name] = s.reportTest(st, externalTest{
+			suite:      p.name,
+			name:       test.name,
+			testType:   jsonTestType(test.name),
+			start:      test.start,
+			end:        test.end,
+			status:     test.status,
+			errorMsg:   test.resultMessage("--- FAIL:"),
+			skipReason: logLocationRegex.ReplaceAllString(test.resultMessage("--- SKIP:"), ""),
+			cached:     p.cached,
+		}, parent)
+	}
+
+	exitCode := 0
+	if status == constants.TestStatusFail {
+		exitCode = 1
+	}
+	s.closeAt(exitCode, end)
+}
+
+// resultMessage returns the message logged by the test with the result line starting with
+// prefix, such as --- FAIL: or --- SKIP:. Without -v, go test prints the entries logged by a test
+// between its result line and the next event, which are all returned. With -v, they're printed as
+// they're logged, before the result line, and the last one is returned, as the failure or skip
+// ends the test.
+func (t *jsonTest) resultMessage(prefix string) string {
+	var before, after []string
+	entries := &before
+	indent := 0
+	found := false
+lines:
+	for _, output := range t.output {
+		for _, line := range strings.Split(output, "\n") {
+			trimmed := strings.TrimSpace(line)
+			switch {
+			case trimmed == "":
+				continue
+			case strings.HasPrefix(trimmed, prefix):
+				entries, found = &after, true
+				continue
+			case strings.HasPrefix(trimmed, "=== ") || strings.HasPrefix(trimmed, "--- "):
+				if found {
+					break lines
+				}
+				continue
+			}
+
+			// The lines indented more than the first line of an entry continue it.
+			lineIndent := len(line) - len(strings.TrimLeft(line, " \t"))
+			if n := len(*entries); n > 0 && lineIndent > indent {
+				(*entries)[n-1] += "\n" + trimmed
+			} else {
+				*entries = append(*entries, trimmed)
+				indent = lineIndent
+			}
+		}
+	}
+
+	switch {
+	case !found:
+		return ""
+	case len(after) > 0:
+		return strings.Join(after, "\n")
+	case len(before) > 0:
+		return before[len(before)-1]
+	}
+	return ""
+}
+
+// jsonTestType returns the type of a test from its name, as go test -json only reports tests,
+// examples and the seed corpus of fuzz tests.
+func jsonTestType(name string) string {
+	switch {
+	case strings.HasPrefix(name, "Example"):
+		return constants.TestTypeExample
+	case strings.HasPrefix(name, "Fuzz"):
+		return constants.TestTypeFuzz
+	}
+	return constants.TestTypeTest
+}
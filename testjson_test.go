@@ -0,0 +1,98 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2021 Datadog, Inc.
+
+package dd_sdk_go_testing
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/DataDog/dd-sdk-go-testing/internal/constants"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+)
+
+const testJSON = `{"Time":"2021-06-01T10:00:00.000Z","Action":"start","Package":"example.com/a"}
+{"Time":"2021-06-01T10:00:00.100Z","Action":"run","Package":"example.com/a","Test":"TestTable"}
+{"Time":"2021-06-01T10:00:00.100Z","Action":"output","Package":"example.com/a","Test":"TestTable","Output":"=== RUN   TestTable\n"}
+{"Time":"2021-06-01T10:00:00.200Z","Action":"run","Package":"example.com/a","Test":"TestTable/fail"}
+{"Time":"2021-06-01T10:00:00.300Z","Action":"output","Package":"example.com/a","Test":"TestTable/fail","Output":"    a_test.go:11: got 1\n"}
+{"Time":"2021-06-01T10:00:00.300Z","Action":"output","Package":"example.com/a","Test":"TestTable/fail","Output":"    a_test.go:12: unexpected value\n"}
+{"Time":"2021-06-01T10:00:00.300Z","Action":"output","Package":"example.com/a","Test":"TestTable/fail","Output":"        want 2\n"}
+{"Time":"2021-06-01T10:00:00.300Z","Action":"output","Package":"example.com/a","Test":"TestTable/fail","Output":"    --- FAIL: TestTable/fail (0.10s)\n"}
+{"Time":"2021-06-01T10:00:00.300Z","Action":"fail","Package":"example.com/a","Test":"TestTable/fail","Elapsed":0.1}
+{"Time":"2021-06-01T10:00:00.400Z","Action":"run","Package":"example.com/a","Test":"TestTable/skip"}
+{"Time":"2021-06-01T10:00:00.400Z","Action":"output","Package":"example.com/a","Test":"TestTable/skip","Output":"    a_test.go:20: not supported\n"}
+{"Time":"2021-06-01T10:00:00.400Z","Action":"output","Package":"example.com/a","Test":"TestTable/skip","Output":"    --- SKIP: TestTable/skip (0.00s)\n"}
+{"Time":"2021-06-01T10:00:00.400Z","Action":"skip","Package":"example.com/a","Test":"TestTable/skip","Elapsed":0}
+{"Time":"2021-06-01T10:00:00.500Z","Action":"fail","Package":"example.com/a","Test":"TestTable","Elapsed":0.4}
+{"Time":"2021-06-01T10:00:00.500Z","Action":"run","Package":"example.com/a","Test":"TestQuiet"}
+{"Time":"2021-06-01T10:00:00.500Z","Action":"output","Package":"example.com/a","Test":"TestQuiet","Output":"--- FAIL: TestQuiet (0.00s)\n"}
+{"Time":"2021-06-01T10:00:00.500Z","Action":"output","Package":"example.com/a","Test":"TestQuiet","Output":"    a_test.go:30: first\n"}
+{"Time":"2021-06-01T10:00:00.500Z","Action":"output","Package":"example.com/a","Test":"TestQuiet","Output":"    a_test.go:31: second\n"}
+{"Time":"2021-06-01T10:00:00.500Z","Action":"fail","Package":"example.com/a","Test":"TestQuiet","Elapsed":0}
+{"Time":"2021-06-01T10:00:00.600Z","Action":"output","Package":"example.com/a","Output":"FAIL\texample.com/a\t0.600s\n"}
+{"Time":"2021-06-01T10:00:00.600Z","Action":"fail","Package":"example.com/a","Elapsed":0.6}
+{"Time":"2021-06-01T10:00:01.000Z","Action":"output","Package":"example.com/b","Output":"?   \texample.com/b\t[no test files]\n"}
+{"Time":"2021-06-01T10:00:01.000Z","Action":"skip","Package":"example.com/b","Elapsed":0}
+{"Time":"2021-06-01T10:00:02.000Z","Action":"run","Package":"example.com/c","Test":"ExampleHello"}
+{"Time":"2021-06-01T10:00:02.000Z","Action":"pass","Package":"example.com/c","Test":"ExampleHello","Elapsed":0}
+{"Time":"2021-06-01T10:00:02.000Z","Action":"output","Package":"example.com/c","Output":"ok  \texample.com/c\t(cached)\n"}
+{"Time":"2021-06-01T10:00:02.000Z","Action":"pass","Package":"example.com/c","Elapsed":0}
+`
+
+func TestConvertTestJSON(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	out := new(bytes.Buffer)
+	exitCode, err := convertTestJSON(strings.NewReader(testJSON), out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exitCode != 1 {
+		t.Fatalf("unexpected exit code: %d", exitCode)
+	}
+	assertEqual(testJSON, out.String())
+
+	tests := map[string]mocktracer.Span{}
+	modules := map[string]mocktracer.Span{}
+	for _, s := range mt.FinishedSpans() {
+		switch s.Tag(ext.SpanType) {
+		case constants.SpanTypeTest:
+			tests[s.Tag(constants.TestName).(string)] = s
+		case constants.SpanTypeTestModule:
+			modules[s.Tag(constants.TestModule).(string)] = s
+		}
+	}
+	if len(tests) != 5 || len(modules) != 2 {
+		t.Fatalf("unexpected spans: %d tests, %d modules", len(tests), len(modules))
+	}
+
+	parent, fail, skip := tests["TestTable"], tests["TestTable/fail"], tests["TestTable/skip"]
+	assertEqual(constants.TestStatusFail, parent.Tag(constants.TestStatus).(string))
+	assertEqual("example.com/a.TestTable/fail", fail.Tag(ext.ResourceName).(string))
+	assertEqual("example.com/a", fail.Tag(constants.TestSuite).(string))
+	assertEqual(constants.TestStatusFail, fail.Tag(constants.TestStatus).(string))
+	assertEqual("a_test.go:12: unexpected value\nwant 2", fail.Tag(ext.ErrorMsg).(string))
+	assertEqual("100ms", fail.FinishTime().Sub(fail.StartTime()).String())
+	assertEqual(constants.TestStatusSkip, skip.Tag(constants.TestStatus).(string))
+	assertEqual("not supported", skip.Tag(constants.TestSkipReason).(string))
+	// Without -v, the entries logged by a test follow its result line.
+	assertEqual("a_test.go:30: first\na_test.go:31: second", tests["TestQuiet"].Tag(ext.ErrorMsg).(string))
+	if fail.ParentID() != parent.SpanID() || skip.ParentID() != parent.SpanID() {
+		t.Fatal("the subtest spans are not children of the test span")
+	}
+	if fail.Tag(constants.TestIsCached) != nil {
+		t.Fatal("unexpected cached test")
+	}
+
+	example := tests["ExampleHello"]
+	assertEqual(constants.TestTypeExample, example.Tag(constants.TestType).(string))
+	assertEqual("true", example.Tag(constants.TestIsCached).(string))
+	assertEqual("true", modules["example.com/c"].Tag(constants.TestIsCached).(string))
+	assertEqual(constants.TestStatusFail, modules["example.com/a"].Tag(constants.TestStatus).(string))
+}